@@ -18,6 +18,8 @@ import (
 	"flag"
 	"os"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -26,10 +28,12 @@ import (
 
 	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
 	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/cluster"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/nodedrain"
 	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/pd"
 	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/pdgroup"
 	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/tikv"
 	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/tikvgroup"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
 	"github.com/zhangjinpeng87/tikv-operator/pkg/scheme"
 )
 
@@ -71,32 +75,40 @@ func main() {
 		os.Exit(1)
 	}
 
+	// PD clients are shared by all controllers
+	pdc := pdapi.NewDefaultPDControl(kubernetes.NewForConfigOrDie(mgr.GetConfig()))
+
 	// Setup controllers
-	if err = cluster.Setup(mgr); err != nil {
+	if err = cluster.Setup(mgr, pdc); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
 	}
 
-	if err = pdgroup.Setup(mgr); err != nil {
+	if err = pdgroup.Setup(mgr, pdc); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PDGroup")
 		os.Exit(1)
 	}
 
-	if err = pd.Setup(mgr); err != nil {
+	if err = pd.Setup(mgr, pdc); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PD")
 		os.Exit(1)
 	}
 
-	if err = tikvgroup.Setup(mgr); err != nil {
+	if err = tikvgroup.Setup(mgr, pdc); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "TiKVGroup")
 		os.Exit(1)
 	}
 
-	if err = tikv.Setup(mgr); err != nil {
+	if err = tikv.Setup(mgr, pdc); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "TiKV")
 		os.Exit(1)
 	}
 
+	if err = nodedrain.Setup(mgr, pdc); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NodeDrain")
+		os.Exit(1)
+	}
+
 	// Setup health checks
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -133,5 +145,14 @@ func addIndexer(ctx context.Context, mgr ctrl.Manager) error {
 		return err
 	}
 
+	// Index Pods by node name to find TiKV pods on a draining node
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &corev1.Pod{}, nodedrain.IndexPodNodeName,
+		func(obj client.Object) []string {
+			pod := obj.(*corev1.Pod)
+			return []string{pod.Spec.NodeName}
+		}); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -54,6 +54,85 @@ type ClusterSpec struct {
 	// RevisionHistoryLimit is the maximum number of revisions that will
 	// be maintained in each Group's revision history
 	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// Monitoring configures how metrics of PD and TiKV are scraped by Prometheus.
+	// Metrics are not advertised if it's not specified.
+	// +optional
+	Monitoring *Monitoring `json:"monitoring,omitempty"`
+
+	// ConsistencyCheck configures how stores and members in PD without a CR are handled.
+	// Discrepancies between PD and CRs are always reported in status.
+	// +optional
+	ConsistencyCheck *ConsistencyCheck `json:"consistencyCheck,omitempty"`
+
+	// PVCRetentionPolicy defines whether PVCs are kept after their instances are deleted.
+	// It can be overridden by each group, the default is Delete.
+	// +optional
+	PVCRetentionPolicy PVCRetentionPolicy `json:"pvcRetentionPolicy,omitempty"`
+
+	// ClusterDomain is the DNS domain of the Kubernetes cluster, e.g. cluster.local.
+	// If it's specified, advertise addresses of PD and TiKV include the namespace and the domain,
+	// so they can be resolved from other namespaces. It should be set before the cluster is created.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="cluster domain is immutable"
+	// +optional
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+}
+
+// MonitorKind is the kind of monitor generated for Prometheus Operator
+type MonitorKind string
+
+const (
+	MonitorKindPodMonitor     MonitorKind = "PodMonitor"
+	MonitorKindServiceMonitor MonitorKind = "ServiceMonitor"
+)
+
+// Monitoring defines how PD and TiKV are scraped by Prometheus
+type Monitoring struct {
+	// Kind is the kind of monitor generated for each component if the Prometheus Operator CRD is installed.
+	// If the CRD is absent, pods are annotated with prometheus.io/* scrape annotations instead.
+	// +kubebuilder:validation:Enum=PodMonitor;ServiceMonitor
+	// +kubebuilder:default=PodMonitor
+	// +optional
+	Kind MonitorKind `json:"kind,omitempty"`
+
+	// Interval is the scrape interval, e.g. 30s.
+	// The global interval of Prometheus is used if it's not specified.
+	// +optional
+	Interval string `json:"interval,omitempty"`
+
+	// Labels are added to generated monitors, e.g. to be selected by a Prometheus
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ConsistencyCheck defines how discrepancies between PD and CRs are handled
+type ConsistencyCheck struct {
+	// Cleanup removes tombstone stores and unhealthy PD members without a PD CR.
+	// Alive stores without a TiKV CR are only reported.
+	// +optional
+	Cleanup bool `json:"cleanup,omitempty"`
+
+	// DryRun only records events of what would be removed by cleanup
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// ConsistencyStatus is the result of the last consistency check between PD and CRs
+type ConsistencyStatus struct {
+	// LastCheckTime is when PD and CRs are cross-referenced last time
+	LastCheckTime metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// OrphanStores are ids of stores in PD which are not tombstone and have no TiKV CR
+	OrphanStores []string `json:"orphanStores,omitempty"`
+
+	// TombstoneStores are ids of tombstone stores which are not removed from PD
+	TombstoneStores []string `json:"tombstoneStores,omitempty"`
+
+	// StaleMembers are names of PD members which have no PD CR
+	StaleMembers []string `json:"staleMembers,omitempty"`
+
+	// MissingStores are names of TiKV CRs whose stores are registered but not found in PD
+	MissingStores []string `json:"missingStores,omitempty"`
 }
 
 // ClusterStatus defines the observed state of Cluster
@@ -69,8 +148,12 @@ type ClusterStatus struct {
 	// ID is the cluster id
 	ID string `json:"id"`
 
-	// PD means url of the pd service, e.g. http://pd:2379
+	// PD means url of the pd service, e.g. http://basic-pd.default:2379
 	PD string `json:"pd,omitempty"`
+
+	// Consistency is the result of the last consistency check between PD and CRs
+	// +optional
+	Consistency *ConsistencyStatus `json:"consistency,omitempty"`
 }
 
 // ComponentKind represents the kind of component
@@ -92,4 +175,9 @@ const (
 	ClusterCondAvailable = "Available"
 	// ClusterCondProgressing means the cluster is progressing
 	ClusterCondProgressing = "Progressing"
+	// ClusterCondConsistent means all stores and members in PD match CRs
+	ClusterCondConsistent        = "Consistent"
+	ReasonConsistent             = "Consistent"
+	ReasonInconsistent           = "Inconsistent"
+	ReasonConsistencyCheckFailed = "CheckFailed"
 )
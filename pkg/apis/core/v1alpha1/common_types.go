@@ -50,6 +50,49 @@ const (
 	ReasonNotAllInstancesUpToDate = "NotAllInstancesUpToDate"
 	ReasonPodNotUpToDate          = "PodNotUpToDate"
 	ReasonPodNotDeleted           = "PodNotDeleted"
+	// VolumesResized means storage of all PVCs of an instance matches the specified size
+	CondVolumesResized             = "VolumesResized"
+	ReasonVolumesResized           = CondVolumesResized
+	ReasonVolumeResizing           = "Resizing"
+	ReasonFileSystemResizePending  = "FileSystemResizePending"
+	ReasonVolumeExpansionForbidden = "ExpansionNotAllowed"
+	ReasonVolumeShrinkForbidden    = "ShrinkNotSupported"
+
+	// ScaleInLimited means a group can't be scaled in to the desired replicas, e.g. not enough
+	// stores would be left for the replication factor of PD
+	CondScaleInLimited      = "ScaleInLimited"
+	ReasonBelowMinReplicas  = "BelowMinReplicas"
+	ReasonScaleInNotLimited = "NotLimited"
+
+	// ReasonApplyConflict means a managed resource can't be applied because
+	// some of its fields are owned by another field manager
+	ReasonApplyConflict = "ApplyConflict"
+)
+
+// Reasons of events.
+// An event of a transition shares the reason of the corresponding condition if there is one,
+// e.g. ReasonSuspended, ReasonPodNotUpToDate and ReasonOfflineProcessing.
+const (
+	ReasonInstanceCreated   = "InstanceCreated"
+	ReasonInstanceDeleted   = "InstanceDeleted"
+	ReasonInstanceUpdated   = "InstanceUpdated"
+	ReasonInstanceReplacing = "InstanceReplacing"
+	ReasonPodCreated        = "PodCreated"
+	ReasonConfigUpdated     = "ConfigUpdated"
+	ReasonVolumeExpanding   = "VolumeExpanding"
+	ReasonPodRestarted      = "PodRestarted"
+	ReasonPVCRetained       = "PVCRetained"
+	ReasonPVCReadopted      = "PVCReadopted"
+
+	ReasonLeaderEvictionStarted  = "LeaderEvictionStarted"
+	ReasonLeaderEvictionFinished = "LeaderEvictionFinished"
+	ReasonPDLeaderTransferred    = "PDLeaderTransferred"
+	ReasonLeakedSchedulerRemoved = "LeakedSchedulerRemoved"
+	ReasonTombstoneStoresRemoved = "TombstoneStoresRemoved"
+	ReasonStaleMemberRemoved     = "StaleMemberRemoved"
+
+	ReasonPDAPIError      = "PDAPIError"
+	ReasonReconcileFailed = "ReconcileFailed"
 )
 
 const (
@@ -69,17 +112,59 @@ const (
 	// LabelKeyInstance means the instance of the resource
 	LabelKeyInstance = KeyPrefix + "instance"
 
+	// LabelKeyHeadlessService marks the headless service of a component, it's the only service scraped by ServiceMonitors
+	LabelKeyHeadlessService = KeyPrefix + "headless-service"
+
 	// LabelKeyPodSpecHash is the hash of the pod spec
 	LabelKeyPodSpecHash = KeyPrefix + "pod-spec-hash"
 
 	// LabelKeyInstanceRevisionHash is the revision hash of the instance
 	LabelKeyInstanceRevisionHash = KeyPrefix + "instance-revision-hash"
 
-	// LabelKeyConfigHash is the hash of the user-specified config
+	// LabelKeyConfigHash is the hash of the rendered config and start script of an instance, the pod is restarted if it's changed
 	LabelKeyConfigHash = KeyPrefix + "config-hash"
 
 	// LabelKeyVolumeName is used to distinguish different volumes
 	LabelKeyVolumeName = KeyPrefix + "volume-name"
+
+	// LabelKeyRetained marks a PVC which is retained after its instance is deleted.
+	// It's removed when the PVC is re-adopted by an instance with the same name.
+	LabelKeyRetained = KeyPrefix + "retained"
+)
+
+const (
+	// AnnoKeyScaleInFirst marks an instance to be deleted first when its group scales in,
+	// e.g. the store is on a bad node or disk
+	AnnoKeyScaleInFirst = KeyPrefix + "scale-in-first"
+
+	// AnnoKeyReplacing marks an instance which is being replaced by a new instance of its group.
+	// It's set by the operator if volumes of the instance can't be changed in place.
+	AnnoKeyReplacing = KeyPrefix + "replacing"
+
+	// AnnoKeyRestartPod marks an instance whose outdated pod may be recreated.
+	// It's set by the group on one instance at a time and removed once the pod is up to date.
+	AnnoKeyRestartPod = KeyPrefix + "restart-pod"
+
+	// AnnoKeyScaledIn marks an instance deleted by its group on scale in or replacement, and PVCs retained by it.
+	// The store or member of the instance is removed from the cluster, so such PVCs are never re-adopted.
+	AnnoKeyScaledIn = KeyPrefix + "scaled-in"
+
+	// AnnoKeyPVCRetentionPolicy is the retention policy of a PVC resolved from its group and cluster
+	AnnoKeyPVCRetentionPolicy = KeyPrefix + "pvc-retention-policy"
+)
+
+const (
+	// Finalizer is added to instances to retain their PVCs before they are deleted
+	Finalizer = KeyPrefix + "finalizer"
+)
+
+const (
+	// ConfigMapKeyConfig is the key of the config file in the ConfigMap of an instance
+	ConfigMapKeyConfig = "config-file"
+	// ConfigMapKeyStartScript is the key of the start script in the ConfigMap of an instance
+	ConfigMapKeyStartScript = "start.sh"
+	// VolumeNameConfig is the name of the pod volume which mounts the ConfigMap of an instance
+	VolumeNameConfig = "config"
 )
 
 const (
@@ -153,12 +238,28 @@ type Volume struct {
 	StorageClassName *string           `json:"storageClassName,omitempty"`
 }
 
+// PVCRetentionPolicy defines whether PVCs are kept after their instances are deleted
+// +kubebuilder:validation:Enum=Delete;Retain;RetainOnClusterDelete
+type PVCRetentionPolicy string
+
+const (
+	// PVCRetentionPolicyDelete deletes PVCs with their instances
+	PVCRetentionPolicyDelete PVCRetentionPolicy = "Delete"
+	// PVCRetentionPolicyRetain keeps PVCs whenever their instances are deleted
+	PVCRetentionPolicyRetain PVCRetentionPolicy = "Retain"
+	// PVCRetentionPolicyRetainOnClusterDelete deletes PVCs on scale in,
+	// but keeps them if the group or the cluster is deleted
+	PVCRetentionPolicyRetainOnClusterDelete PVCRetentionPolicy = "RetainOnClusterDelete"
+)
+
 type VolumeMount struct {
 	Type      VolumeMountType `json:"type"`
 	MountPath string          `json:"mountPath,omitempty"`
 	SubPath   string          `json:"subPath,omitempty"`
 }
 
+// VolumeMountType is the usage of a mounted volume, each type can be mounted at most once in an instance
+// +kubebuilder:validation:Enum=data;raft-engine;wal;log;titan
 type VolumeMountType string
 
 const (
@@ -166,16 +267,85 @@ const (
 	VolumeMountTypePDData VolumeMountType = "data"
 	// VolumeMountTypeTiKVData means data dir of TiKV
 	VolumeMountTypeTiKVData VolumeMountType = "data"
-
-	VolumeMountPDDataDefaultPath   = "/var/lib/pd"
-	VolumeMountTiKVDataDefaultPath = "/var/lib/tikv"
+	// VolumeMountTypeTiKVRaftEngine means dir of raft engine of TiKV, i.e. raft-engine.dir
+	VolumeMountTypeTiKVRaftEngine VolumeMountType = "raft-engine"
+	// VolumeMountTypeTiKVWAL means dir of the WAL of RocksDB of TiKV, i.e. rocksdb.wal-dir
+	VolumeMountTypeTiKVWAL VolumeMountType = "wal"
+	// VolumeMountTypeTiKVLog means dir of log files of TiKV, i.e. log.file.filename is in it
+	VolumeMountTypeTiKVLog VolumeMountType = "log"
+	// VolumeMountTypeTiKVTitan means dir of blob files of Titan of TiKV, i.e. rocksdb.titan.dirname
+	VolumeMountTypeTiKVTitan VolumeMountType = "titan"
+
+	VolumeMountPDDataDefaultPath         = "/var/lib/pd"
+	VolumeMountTiKVDataDefaultPath       = "/var/lib/tikv"
+	VolumeMountTiKVRaftEngineDefaultPath = "/var/lib/tikv-raft-engine"
+	VolumeMountTiKVWALDefaultPath        = "/var/lib/tikv-wal"
+	VolumeMountTiKVLogDefaultPath        = "/var/log/tikv"
+	VolumeMountTiKVTitanDefaultPath      = "/var/lib/tikv-titan"
 )
 
 // Port defines a listen port
 type Port struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
 	Port int32 `json:"port"`
 }
 
+// NetworkMode is the network mode of pods
+type NetworkMode string
+
+const (
+	// NetworkModePod means pods have their own network and are advertised by DNS names of the headless service
+	NetworkModePod NetworkMode = "Pod"
+	// NetworkModeHost means pods use the network of nodes and are advertised by the pod ip
+	NetworkModeHost NetworkMode = "Host"
+)
+
+// Network defines the network of pods
+type Network struct {
+	// Mode is the network mode of pods, default is Pod.
+	// With Host, ports must not conflict with other pods on the same node.
+	// +kubebuilder:validation:Enum=Pod;Host
+	// +optional
+	Mode NetworkMode `json:"mode,omitempty"`
+
+	// IPFamily is the ip family of listen and advertised addresses, default is IPv4.
+	// Set it to IPv6 for IPv6-only clusters.
+	// +kubebuilder:validation:Enum=IPv4;IPv6
+	// +optional
+	IPFamily corev1.IPFamily `json:"ipFamily,omitempty"`
+}
+
+// IsHost returns whether pods use the network of nodes
+func (n *Network) IsHost() bool {
+	return n.Mode == NetworkModeHost
+}
+
+// IsIPv6 returns whether pods listen on and advertise IPv6 addresses
+func (n *Network) IsIPv6() bool {
+	return n.IPFamily == corev1.IPv6Protocol
+}
+
+// ExternalService defines a service to access instances from outside of the Kubernetes cluster
+type ExternalService struct {
+	// Type is the type of the service
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer
+	Type corev1.ServiceType `json:"type"`
+
+	// Annotations are added to the service, e.g. to configure the load balancer
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// LoadBalancerSourceRanges restricts clients of a LoadBalancer service
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// ExternalTrafficPolicy defines how external traffic is routed to pods
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+}
+
 // SchedulePolicy defines how instances of the group schedules its pod
 type SchedulePolicy struct {
 	Type         SchedulePolicyType          `json:"type"`
@@ -227,6 +397,32 @@ type UpdateStrategy struct {
 	Config ConfigUpdateStrategy `json:"config,omitempty"`
 }
 
+// Probes overrides settings of the probes of the main container.
+// Handlers of probes are generated by the operator and can't be changed.
+type Probes struct {
+	// Readiness overrides settings of the readiness probe
+	Readiness *ProbeSettings `json:"readiness,omitempty"`
+	// Liveness overrides settings of the liveness probe
+	Liveness *ProbeSettings `json:"liveness,omitempty"`
+	// Startup overrides settings of the startup probe
+	Startup *ProbeSettings `json:"startup,omitempty"`
+}
+
+// ProbeSettings defines timing and threshold settings of a probe.
+// Unspecified fields keep the defaults of the operator.
+type ProbeSettings struct {
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	SuccessThreshold *int32 `json:"successThreshold,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
 // TLS defines a common tls config for all components
 type TLS struct {
 	Enabled bool `json:"enabled,omitempty"`
@@ -0,0 +1,143 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+// Accessors shared by all groups and instances.
+// They allow controllers to handle different components in a generic way.
+
+func (in *PDGroup) ClusterName() string {
+	return in.Spec.Cluster.Name
+}
+
+func (in *PDGroup) Component() string {
+	return LabelValComponentPD
+}
+
+func (in *PDGroup) GetReplicas() int32 {
+	if in.Spec.Replicas == nil {
+		return 0
+	}
+	return *in.Spec.Replicas
+}
+
+func (in *PDGroup) IsSuspended() bool {
+	return in.Spec.Suspend
+}
+
+func (in *PDGroup) GetPVCRetentionPolicy() PVCRetentionPolicy {
+	return in.Spec.PVCRetentionPolicy
+}
+
+func (in *PDGroup) GetScaleInInstances() []string {
+	return in.Spec.ScaleInInstances
+}
+
+func (in *PDGroup) GetCommonStatus() *CommonStatus {
+	return &in.Status.CommonStatus
+}
+
+func (in *PDGroup) GetGroupStatus() *GroupStatus {
+	return &in.Status.GroupStatus
+}
+
+func (in *PD) ClusterName() string {
+	return in.Spec.Cluster.Name
+}
+
+func (in *PD) Component() string {
+	return LabelValComponentPD
+}
+
+func (in *PD) GetCommonStatus() *CommonStatus {
+	return &in.Status.CommonStatus
+}
+
+func (in *PD) GetConfig() string {
+	return in.Spec.Config
+}
+
+func (in *PD) GetVolumes() []Volume {
+	return in.Spec.Volumes
+}
+
+func (in *PD) GetResources() ResourceRequirements {
+	return in.Spec.Resources
+}
+
+func (in *PD) GetNetwork() *Network {
+	return &in.Spec.Network
+}
+
+func (in *TiKVGroup) ClusterName() string {
+	return in.Spec.Cluster.Name
+}
+
+func (in *TiKVGroup) Component() string {
+	return LabelValComponentTiKV
+}
+
+func (in *TiKVGroup) GetReplicas() int32 {
+	if in.Spec.Replicas == nil {
+		return 0
+	}
+	return *in.Spec.Replicas
+}
+
+func (in *TiKVGroup) IsSuspended() bool {
+	return in.Spec.Suspend
+}
+
+func (in *TiKVGroup) GetPVCRetentionPolicy() PVCRetentionPolicy {
+	return in.Spec.PVCRetentionPolicy
+}
+
+func (in *TiKVGroup) GetScaleInInstances() []string {
+	return in.Spec.ScaleInInstances
+}
+
+func (in *TiKVGroup) GetCommonStatus() *CommonStatus {
+	return &in.Status.CommonStatus
+}
+
+func (in *TiKVGroup) GetGroupStatus() *GroupStatus {
+	return &in.Status.GroupStatus
+}
+
+func (in *TiKV) ClusterName() string {
+	return in.Spec.Cluster.Name
+}
+
+func (in *TiKV) Component() string {
+	return LabelValComponentTiKV
+}
+
+func (in *TiKV) GetCommonStatus() *CommonStatus {
+	return &in.Status.CommonStatus
+}
+
+func (in *TiKV) GetConfig() string {
+	return in.Spec.Config
+}
+
+func (in *TiKV) GetVolumes() []Volume {
+	return in.Spec.Volumes
+}
+
+func (in *TiKV) GetResources() ResourceRequirements {
+	return in.Spec.Resources
+}
+
+func (in *TiKV) GetNetwork() *Network {
+	return &in.Spec.Network
+}
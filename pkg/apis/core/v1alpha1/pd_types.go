@@ -108,10 +108,29 @@ type PDGroupSpec struct {
 	// Bootstrapped means that pd cluster has been bootstrapped
 	Bootstrapped bool `json:"bootstrapped,omitempty"`
 
+	// Suspend gracefully stops all pods of this group.
+	// PVCs and instance CRs are kept so the group can be resumed later.
+	Suspend bool `json:"suspend,omitempty"`
+
 	// +listType=map
 	// +listMapKey=type
 	SchedulePolicies []SchedulePolicy `json:"schedulePolicies,omitempty"`
 
+	// PVCRetentionPolicy overrides the PVC retention policy of the cluster for this group
+	// +optional
+	PVCRetentionPolicy PVCRetentionPolicy `json:"pvcRetentionPolicy,omitempty"`
+
+	// ScaleInInstances are names of instances which are deleted first when the group scales in,
+	// e.g. instances on a bad node or disk. It works like the tikv.org/scale-in-first annotation of instances.
+	// New instances don't reuse the names while they're listed.
+	// +listType=set
+	// +optional
+	ScaleInInstances []string `json:"scaleInInstances,omitempty"`
+
+	// Service exposes the client port of PD outside of the Kubernetes cluster
+	// +optional
+	Service *ExternalService `json:"service,omitempty"`
+
 	Template PDTemplate `json:"template"`
 }
 
@@ -134,13 +153,37 @@ type PDTemplateSpec struct {
 	// Config defines config file of PD (TOML format)
 	Config string `json:"config,omitempty"`
 
+	// Server defines the server configuration of PD
+	Server PDServer `json:"server,omitempty"`
+
+	// Network defines the network of PD pods.
+	// It changes advertised peer urls of members, so it should be set before the cluster is created.
+	Network Network `json:"network,omitempty"`
+
 	// Volumes defines persistent volumes of PD
 	Volumes []Volume `json:"volumes"`
 
+	// Probes overrides settings of the default probes
+	Probes Probes `json:"probes,omitempty"`
+
 	// Overlay defines a k8s native resource template patch
 	Overlay *Overlay `json:"overlay,omitempty"`
 }
 
+// PDServer defines the server configuration of PD
+type PDServer struct {
+	// Ports defines all ports listened by PD.
+	// All PD groups of a cluster should use the same ports, they share the headless service.
+	Ports PDPorts `json:"ports,omitempty"`
+}
+
+type PDPorts struct {
+	// Client defines port for PD's api service, default is 2379
+	Client *Port `json:"client,omitempty"`
+	// Peer defines port for the communication between PD members, default is 2380
+	Peer *Port `json:"peer,omitempty"`
+}
+
 type PDGroupStatus struct {
 	CommonStatus `json:",inline"`
 	GroupStatus  `json:",inline"`
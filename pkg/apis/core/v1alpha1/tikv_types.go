@@ -129,10 +129,38 @@ type TiKVGroupSpec struct {
 	// +kubebuilder:validation:Minimum=0
 	Replicas *int32 `json:"replicas"`
 
+	// Suspend gracefully stops all pods of this group.
+	// PVCs and instance CRs are kept so the group can be resumed later.
+	Suspend bool `json:"suspend,omitempty"`
+
 	// +listType=map
 	// +listMapKey=type
 	SchedulePolicies []SchedulePolicy `json:"schedulePolicies,omitempty"`
 
+	// PVCRetentionPolicy overrides the PVC retention policy of the cluster for this group
+	// +optional
+	PVCRetentionPolicy PVCRetentionPolicy `json:"pvcRetentionPolicy,omitempty"`
+
+	// ScaleInInstances are names of instances which are deleted first when the group scales in,
+	// e.g. instances on a bad node or disk. It works like the tikv.org/scale-in-first annotation of instances.
+	// New instances don't reuse the names while they're listed.
+	// +listType=set
+	// +optional
+	ScaleInInstances []string `json:"scaleInInstances,omitempty"`
+
+	// Service exposes the client port of all TiKVs of this group outside of the Kubernetes cluster.
+	// Clients connect to individual stores, see server.service of the template for a service of each TiKV.
+	// +optional
+	Service *ExternalService `json:"service,omitempty"`
+
+	// VolumeReplaceConcurrency is the max number of instances replaced at the same time
+	// when their volumes can't be changed in place, e.g. the storage class is changed or a volume is shrunk.
+	// A new instance is created with the new volumes and the old one is offlined after the new one is available.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	VolumeReplaceConcurrency *int32 `json:"volumeReplaceConcurrency,omitempty"`
+
 	Template TiKVTemplate `json:"template"`
 }
 
@@ -155,13 +183,43 @@ type TiKVTemplateSpec struct {
 	// Config defines config file of TiKV (TOML format)
 	Config string `json:"config,omitempty"`
 
+	// Server defines the server configuration of TiKV
+	Server TiKVServer `json:"server,omitempty"`
+
+	// Network defines the network of TiKV pods.
+	// Stores advertise new addresses after they are restarted if it's changed.
+	Network Network `json:"network,omitempty"`
+
 	// Volumes defines persistent volumes of TiKV
 	Volumes []Volume `json:"volumes"`
 
+	// Probes overrides settings of the default probes
+	Probes Probes `json:"probes,omitempty"`
+
 	// Overlay defines a k8s native resource template patch
 	Overlay *Overlay `json:"overlay,omitempty"`
 }
 
+// TiKVServer defines the server configuration of TiKV
+type TiKVServer struct {
+	// Ports defines all ports listened by TiKV.
+	// All TiKV groups of a cluster should use the same ports, they share the headless service.
+	Ports TiKVPorts `json:"ports,omitempty"`
+
+	// Service creates a service for each TiKV to expose its client port outside of the Kubernetes cluster.
+	// The external address of the service is advertised to PD and clients,
+	// i.e. the node ip and node port of a NodePort service, or the ingress of a LoadBalancer service.
+	// +optional
+	Service *ExternalService `json:"service,omitempty"`
+}
+
+type TiKVPorts struct {
+	// Client defines port for TiKV's grpc service, default is 20160
+	Client *Port `json:"client,omitempty"`
+	// Status defines port for TiKV's status api and metrics, default is 20180
+	Status *Port `json:"status,omitempty"`
+}
+
 type TiKVGroupStatus struct {
 	CommonStatus `json:",inline"`
 	GroupStatus  `json:",inline"`
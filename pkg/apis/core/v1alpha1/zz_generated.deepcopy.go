@@ -109,6 +109,16 @@ func (in *ClusterSpec) DeepCopyInto(out *ClusterSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(Monitoring)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConsistencyCheck != nil {
+		in, out := &in.ConsistencyCheck, &out.ConsistencyCheck
+		*out = new(ConsistencyCheck)
+		**out = **in
+	}
 	return
 }
 
@@ -137,6 +147,11 @@ func (in *ClusterStatus) DeepCopyInto(out *ClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Consistency != nil {
+		in, out := &in.Consistency, &out.Consistency
+		*out = new(ConsistencyStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -194,6 +209,87 @@ func (in *ComponentStatus) DeepCopy() *ComponentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsistencyCheck) DeepCopyInto(out *ConsistencyCheck) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsistencyCheck.
+func (in *ConsistencyCheck) DeepCopy() *ConsistencyCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsistencyCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsistencyStatus) DeepCopyInto(out *ConsistencyStatus) {
+	*out = *in
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+	if in.OrphanStores != nil {
+		in, out := &in.OrphanStores, &out.OrphanStores
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TombstoneStores != nil {
+		in, out := &in.TombstoneStores, &out.TombstoneStores
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StaleMembers != nil {
+		in, out := &in.StaleMembers, &out.StaleMembers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MissingStores != nil {
+		in, out := &in.MissingStores, &out.MissingStores
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsistencyStatus.
+func (in *ConsistencyStatus) DeepCopy() *ConsistencyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsistencyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalService) DeepCopyInto(out *ExternalService) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LoadBalancerSourceRanges != nil {
+		in, out := &in.LoadBalancerSourceRanges, &out.LoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalService.
+func (in *ExternalService) DeepCopy() *ExternalService {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalService)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GroupStatus) DeepCopyInto(out *GroupStatus) {
 	*out = *in
@@ -210,6 +306,29 @@ func (in *GroupStatus) DeepCopy() *GroupStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Monitoring) DeepCopyInto(out *Monitoring) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Monitoring.
+func (in *Monitoring) DeepCopy() *Monitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(Monitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamedPersistentVolumeClaimOverlay) DeepCopyInto(out *NamedPersistentVolumeClaimOverlay) {
 	*out = *in
@@ -227,6 +346,22 @@ func (in *NamedPersistentVolumeClaimOverlay) DeepCopy() *NamedPersistentVolumeCl
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Network) DeepCopyInto(out *Network) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
+func (in *Network) DeepCopy() *Network {
+	if in == nil {
+		return nil
+	}
+	out := new(Network)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ObjectMeta) DeepCopyInto(out *ObjectMeta) {
 	*out = *in
@@ -390,6 +525,16 @@ func (in *PDGroupSpec) DeepCopyInto(out *PDGroupSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ScaleInInstances != nil {
+		in, out := &in.ScaleInInstances, &out.ScaleInInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ExternalService)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	return
 }
@@ -455,6 +600,49 @@ func (in *PDList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PDPorts) DeepCopyInto(out *PDPorts) {
+	*out = *in
+	if in.Client != nil {
+		in, out := &in.Client, &out.Client
+		*out = new(Port)
+		**out = **in
+	}
+	if in.Peer != nil {
+		in, out := &in.Peer, &out.Peer
+		*out = new(Port)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PDPorts.
+func (in *PDPorts) DeepCopy() *PDPorts {
+	if in == nil {
+		return nil
+	}
+	out := new(PDPorts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PDServer) DeepCopyInto(out *PDServer) {
+	*out = *in
+	in.Ports.DeepCopyInto(&out.Ports)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PDServer.
+func (in *PDServer) DeepCopy() *PDServer {
+	if in == nil {
+		return nil
+	}
+	out := new(PDServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PDSpec) DeepCopyInto(out *PDSpec) {
 	*out = *in
@@ -525,6 +713,8 @@ func (in *PDTemplateSpec) DeepCopyInto(out *PDTemplateSpec) {
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	out.UpdateStrategy = in.UpdateStrategy
+	in.Server.DeepCopyInto(&out.Server)
+	out.Network = in.Network
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]Volume, len(*in))
@@ -532,6 +722,7 @@ func (in *PDTemplateSpec) DeepCopyInto(out *PDTemplateSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.Probes.DeepCopyInto(&out.Probes)
 	if in.Overlay != nil {
 		in, out := &in.Overlay, &out.Overlay
 		*out = new(Overlay)
@@ -610,6 +801,78 @@ func (in *Port) DeepCopy() *Port {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeSettings) DeepCopyInto(out *ProbeSettings) {
+	*out = *in
+	if in.InitialDelaySeconds != nil {
+		in, out := &in.InitialDelaySeconds, &out.InitialDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PeriodSeconds != nil {
+		in, out := &in.PeriodSeconds, &out.PeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SuccessThreshold != nil {
+		in, out := &in.SuccessThreshold, &out.SuccessThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailureThreshold != nil {
+		in, out := &in.FailureThreshold, &out.FailureThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeSettings.
+func (in *ProbeSettings) DeepCopy() *ProbeSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Probes) DeepCopyInto(out *Probes) {
+	*out = *in
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(ProbeSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(ProbeSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(ProbeSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probes.
+func (in *Probes) DeepCopy() *Probes {
+	if in == nil {
+		return nil
+	}
+	out := new(Probes)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceRequirements) DeepCopyInto(out *ResourceRequirements) {
 	*out = *in
@@ -845,6 +1108,21 @@ func (in *TiKVGroupSpec) DeepCopyInto(out *TiKVGroupSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ScaleInInstances != nil {
+		in, out := &in.ScaleInInstances, &out.ScaleInInstances
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ExternalService)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VolumeReplaceConcurrency != nil {
+		in, out := &in.VolumeReplaceConcurrency, &out.VolumeReplaceConcurrency
+		*out = new(int32)
+		**out = **in
+	}
 	in.Template.DeepCopyInto(&out.Template)
 	return
 }
@@ -910,6 +1188,54 @@ func (in *TiKVList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TiKVPorts) DeepCopyInto(out *TiKVPorts) {
+	*out = *in
+	if in.Client != nil {
+		in, out := &in.Client, &out.Client
+		*out = new(Port)
+		**out = **in
+	}
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(Port)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TiKVPorts.
+func (in *TiKVPorts) DeepCopy() *TiKVPorts {
+	if in == nil {
+		return nil
+	}
+	out := new(TiKVPorts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TiKVServer) DeepCopyInto(out *TiKVServer) {
+	*out = *in
+	in.Ports.DeepCopyInto(&out.Ports)
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ExternalService)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TiKVServer.
+func (in *TiKVServer) DeepCopy() *TiKVServer {
+	if in == nil {
+		return nil
+	}
+	out := new(TiKVServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TiKVSpec) DeepCopyInto(out *TiKVSpec) {
 	*out = *in
@@ -981,6 +1307,8 @@ func (in *TiKVTemplateSpec) DeepCopyInto(out *TiKVTemplateSpec) {
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
 	out.UpdateStrategy = in.UpdateStrategy
+	in.Server.DeepCopyInto(&out.Server)
+	out.Network = in.Network
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]Volume, len(*in))
@@ -988,6 +1316,7 @@ func (in *TiKVTemplateSpec) DeepCopyInto(out *TiKVTemplateSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	in.Probes.DeepCopyInto(&out.Probes)
 	if in.Overlay != nil {
 		in, out := &in.Overlay, &out.Overlay
 		*out = new(Overlay)
@@ -0,0 +1,87 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config parses, validates and renders TOML config files of PD and TiKV.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config is a parsed TOML config file, tables are nested maps
+type Config map[string]any
+
+// Parse parses a TOML config file
+func Parse(data string) (Config, error) {
+	cfg := Config{}
+	if _, err := toml.Decode(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid toml: %w", err)
+	}
+	return cfg, nil
+}
+
+// Get returns the value of a dotted key, e.g. storage.block-cache.capacity
+func (c Config) Get(key string) (any, bool) {
+	keys := strings.Split(key, ".")
+	table := map[string]any(c)
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := table[k].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		table = sub
+	}
+	v, ok := table[keys[len(keys)-1]]
+	return v, ok
+}
+
+// SetDefault sets the value of a dotted key if it's not specified by users.
+// It returns whether the value is set, a parent key which is not a table is left to the component to report.
+func (c Config) SetDefault(key string, value any) bool {
+	keys := strings.Split(key, ".")
+	table := map[string]any(c)
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := table[k].(map[string]any)
+		if !ok {
+			if _, exists := table[k]; exists {
+				return false
+			}
+			sub = map[string]any{}
+			table[k] = sub
+		}
+		table = sub
+	}
+	last := keys[len(keys)-1]
+	if _, ok := table[last]; ok {
+		return false
+	}
+	table[last] = value
+	return true
+}
+
+// Render returns the canonical form of the config, keys are sorted and comments are dropped.
+// The same config is always rendered to the same content, so its hash only changes with the config.
+func (c Config) Render() (string, error) {
+	if len(c) == 0 {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(map[string]any(c)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
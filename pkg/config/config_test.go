@@ -0,0 +1,112 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRender(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		config   string
+		defaults map[string]any
+		want     string
+		wantErr  bool
+	}{{
+		caseName: "empty",
+	}, {
+		caseName: "comments and order are canonical",
+		config:   "# comment\n[storage]\nreserve-space = \"1GiB\"\n\n[server]\ngrpc-concurrency = 4\n",
+		want:     "[server]\n  grpc-concurrency = 4\n\n[storage]\n  reserve-space = \"1GiB\"\n",
+	}, {
+		caseName: "defaults are added",
+		defaults: map[string]any{"storage.block-cache.capacity": "1024MiB"},
+		want:     "[storage]\n  [storage.block-cache]\n    capacity = \"1024MiB\"\n",
+	}, {
+		caseName: "user keys win",
+		config:   "[storage.block-cache]\ncapacity = \"2GiB\"\n",
+		defaults: map[string]any{"storage.block-cache.capacity": "1024MiB"},
+		want:     "[storage]\n  [storage.block-cache]\n    capacity = \"2GiB\"\n",
+	}, {
+		caseName: "parent is not a table",
+		config:   "storage = 1\n",
+		defaults: map[string]any{"storage.block-cache.capacity": "1024MiB"},
+		want:     "storage = 1\n",
+	}, {
+		caseName: "invalid toml",
+		config:   "[storage",
+		wantErr:  true,
+	}}
+
+	for _, tc := range tcs {
+		cfg, err := Parse(tc.config)
+		if tc.wantErr {
+			g.Expect(err).To(HaveOccurred(), tc.caseName)
+			continue
+		}
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		for k, v := range tc.defaults {
+			cfg.SetDefault(k, v)
+		}
+		got, err := cfg.Render()
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(got).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		schema   *Schema
+		config   string
+		wantErr  string
+	}{{
+		caseName: "valid tikv",
+		schema:   TiKV,
+		config:   "[storage]\nreserve-space = \"1GiB\"\n[pd]\nretry-interval = \"300ms\"\n",
+	}, {
+		caseName: "unknown key",
+		schema:   TiKV,
+		config:   "[storag]\nreserve-space = \"1GiB\"\n",
+		wantErr:  `invalid config of tikv: unknown key "storag"`,
+	}, {
+		caseName: "forbidden tikv keys",
+		schema:   TiKV,
+		config:   "[storage]\ndata-dir = \"/data\"\n[pd]\nendpoints = [\"pd:2379\"]\n",
+		wantErr:  `invalid config of tikv: key "storage.data-dir" is managed by the operator, key "pd.endpoints" is managed by the operator`,
+	}, {
+		caseName: "forbidden pd key",
+		schema:   PD,
+		config:   "data-dir = \"/data\"\n[replication]\nmax-replicas = 5\n",
+		wantErr:  `invalid config of pd: key "data-dir" is managed by the operator`,
+	}}
+
+	for _, tc := range tcs {
+		cfg, err := Parse(tc.config)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		err = tc.schema.Validate(cfg)
+		if tc.wantErr == "" {
+			g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		} else {
+			g.Expect(err).To(MatchError(tc.wantErr), tc.caseName)
+		}
+	}
+}
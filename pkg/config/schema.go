@@ -0,0 +1,92 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Schema defines which keys of a component config can be specified by users
+type Schema struct {
+	// Component is the name of the component, e.g. tikv
+	Component string
+	// Sections are the known top-level keys of the config
+	Sections sets.Set[string]
+	// Forbidden are dotted keys owned by the operator, e.g. pd.endpoints
+	Forbidden []string
+}
+
+// Validate rejects unknown top-level keys and keys owned by the operator
+func (s *Schema) Validate(c Config) error {
+	var errs []string
+	unknown := []string{}
+	for k := range c {
+		if !s.Sections.Has(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		errs = append(errs, fmt.Sprintf("unknown key %q", k))
+	}
+	for _, k := range s.Forbidden {
+		if _, ok := c.Get(k); ok {
+			errs = append(errs, fmt.Sprintf("key %q is managed by the operator", k))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid config of %s: %s", s.Component, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// PD is the schema of the config of PD
+var PD = &Schema{
+	Component: "pd",
+	Sections: sets.New(
+		"name", "data-dir", "client-urls", "peer-urls", "advertise-client-urls", "advertise-peer-urls",
+		"initial-cluster", "initial-cluster-state", "initial-cluster-token", "join",
+		"lease", "log", "log-level", "log-file", "tso-save-interval", "tso-update-physical-interval",
+		"enable-local-tso", "metric", "schedule", "replication", "pd-server", "cluster-version", "labels",
+		"quota-backend-bytes", "auto-compaction-mode", "auto-compaction-retention",
+		"auto-compaction-retention-v2", "tick-interval", "election-interval", "enable-prevote",
+		"max-request-bytes", "security", "label-property", "enable-grpc-gateway", "dashboard",
+		"replication-mode", "keyspace", "controller", "micro-service", "force-new-cluster",
+		"use-region-storage", "max-concurrent-tso-proxy-streamings", "tso-proxy-recv-from-client-timeout",
+	),
+	Forbidden: []string{
+		"name", "data-dir", "client-urls", "peer-urls", "advertise-client-urls", "advertise-peer-urls",
+	},
+}
+
+// TiKV is the schema of the config of TiKV
+var TiKV = &Schema{
+	Component: "tikv",
+	Sections: sets.New(
+		"log-level", "log-file", "log-format", "slow-log-file", "slow-log-threshold",
+		"log-rotation-timespan", "log-rotation-size", "panic-when-unexpected-key-or-data", "abort-on-panic",
+		"memory-usage-limit", "memory-usage-high-water", "log", "memory", "quota", "readpool", "server",
+		"storage", "pd", "metric", "raftstore", "coprocessor", "coprocessor-v2", "rocksdb", "raftdb",
+		"raft-engine", "security", "import", "backup", "log-backup", "pessimistic-txn", "gc", "split",
+		"cdc", "resolved-ts", "resource-metering", "causal-ts", "resource-control", "in-memory-engine",
+	),
+	Forbidden: []string{
+		"storage.data-dir", "pd.endpoints",
+		"server.addr", "server.advertise-addr", "server.status-addr", "server.advertise-status-addr",
+	},
+}
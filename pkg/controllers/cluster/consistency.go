@@ -0,0 +1,271 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/tikv"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+// checkConsistency cross-references stores and members in PD with TiKV and PD CRs of the cluster.
+// Discrepancies are reported in status, tombstone stores and unhealthy stale members are removed
+// if cleanup is enabled and the cluster is not paused.
+func (r *ClusterReconciler) checkConsistency(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	pdc := common.PDClient(r.PDControl, cluster)
+	status, unhealthyMembers, err := r.crossReference(ctx, pdc, cluster)
+	if err != nil {
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:               v1alpha1.ClusterCondConsistent,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: cluster.Generation,
+			Reason:             v1alpha1.ReasonConsistencyCheckFailed,
+			Message:            err.Error(),
+		})
+		return err
+	}
+	cluster.Status.Consistency = status
+	setConsistentCondition(cluster, status)
+
+	check := cluster.Spec.ConsistencyCheck
+	if cluster.Spec.Paused || check == nil || !check.Cleanup {
+		return nil
+	}
+
+	if len(status.TombstoneStores) > 0 {
+		stores := strings.Join(status.TombstoneStores, ",")
+		if check.DryRun {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, v1alpha1.ReasonTombstoneStoresRemoved,
+				"dry run: tombstone stores %s would be removed", stores)
+		} else {
+			if err := pdc.RemoveTombStoneStores(); err != nil {
+				return fmt.Errorf("can't remove tombstone stores: %w", err)
+			}
+			r.Log.Info("removed tombstone stores", "cluster", cluster.Name, "stores", stores)
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, v1alpha1.ReasonTombstoneStoresRemoved,
+				"tombstone stores %s are removed", stores)
+		}
+	}
+
+	for _, name := range status.StaleMembers {
+		id, ok := unhealthyMembers[name]
+		if !ok {
+			continue
+		}
+		if check.DryRun {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, v1alpha1.ReasonStaleMemberRemoved,
+				"dry run: unhealthy member %s would be removed", name)
+			continue
+		}
+		if err := pdc.DeleteMemberByID(id); err != nil {
+			return fmt.Errorf("can't remove member %s: %w", name, err)
+		}
+		r.Log.Info("removed stale member", "cluster", cluster.Name, "member", name)
+		r.Recorder.Eventf(cluster, corev1.EventTypeNormal, v1alpha1.ReasonStaleMemberRemoved,
+			"unhealthy member %s without a PD is removed", name)
+	}
+	return nil
+}
+
+// crossReference returns discrepancies between PD and CRs, and ids of unhealthy members by names
+func (r *ClusterReconciler) crossReference(ctx context.Context, pdc pdapi.PDClient,
+	cluster *v1alpha1.Cluster) (*v1alpha1.ConsistencyStatus, map[string]uint64, error) {
+	stores, err := pdc.GetStores()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't get stores: %w", err)
+	}
+	tombstones, err := pdc.GetTombStoneStores()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't get tombstone stores: %w", err)
+	}
+	members, err := pdc.GetMembers()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't get members: %w", err)
+	}
+	health, err := pdc.GetHealth()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't get health of members: %w", err)
+	}
+
+	inCluster := client.MatchingLabels{v1alpha1.LabelKeyCluster: cluster.Name}
+	var tikvList v1alpha1.TiKVList
+	if err := r.List(ctx, &tikvList, client.InNamespace(cluster.Namespace), inCluster); err != nil {
+		return nil, nil, err
+	}
+	var pdList v1alpha1.PDList
+	if err := r.List(ctx, &pdList, client.InNamespace(cluster.Namespace), inCluster); err != nil {
+		return nil, nil, err
+	}
+	addrs, err := r.storeAddrs(ctx, cluster, tikvList.Items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status := &v1alpha1.ConsistencyStatus{LastCheckTime: metav1.Now()}
+
+	// Stores of TiKVs which are found in PD, including tombstone ones
+	found := map[string]struct{}{}
+	for _, store := range stores.Stores {
+		if store.Store == nil {
+			continue
+		}
+		name, ok := matchTiKV(tikvList.Items, addrs, store)
+		if !ok {
+			status.OrphanStores = append(status.OrphanStores, strconv.FormatUint(store.Store.GetId(), 10))
+			continue
+		}
+		found[name] = struct{}{}
+	}
+	for _, store := range tombstones.Stores {
+		if store.Store == nil {
+			continue
+		}
+		if name, ok := matchTiKV(tikvList.Items, addrs, store); ok {
+			found[name] = struct{}{}
+		}
+		status.TombstoneStores = append(status.TombstoneStores, strconv.FormatUint(store.Store.GetId(), 10))
+	}
+	for i := range tikvList.Items {
+		kv := &tikvList.Items[i]
+		if _, ok := found[kv.Name]; !ok && kv.Status.ID != "" {
+			status.MissingStores = append(status.MissingStores, kv.Name)
+		}
+	}
+
+	pds := map[string]struct{}{}
+	for i := range pdList.Items {
+		pds[pdList.Items[i].Name] = struct{}{}
+	}
+	unhealthy := map[string]uint64{}
+	for _, h := range health.Healths {
+		if !h.Health {
+			unhealthy[h.Name] = h.MemberID
+		}
+	}
+	for _, member := range members.Members {
+		if _, ok := pds[member.GetName()]; !ok {
+			status.StaleMembers = append(status.StaleMembers, member.GetName())
+		}
+	}
+
+	sort.Strings(status.OrphanStores)
+	sort.Strings(status.TombstoneStores)
+	sort.Strings(status.MissingStores)
+	sort.Strings(status.StaleMembers)
+	return status, unhealthy, nil
+}
+
+// storeAddrs returns the store addresses of TiKVs whose store id is not known yet, by names of TiKVs.
+// A TiKV is left out if its address can't be known yet, e.g. its pod is not scheduled.
+func (r *ClusterReconciler) storeAddrs(ctx context.Context, cluster *v1alpha1.Cluster,
+	tikvs []v1alpha1.TiKV) (map[string]string, error) {
+	inComponent := client.MatchingLabels{
+		v1alpha1.LabelKeyCluster:   cluster.Name,
+		v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
+	}
+	var podList corev1.PodList
+	if err := r.List(ctx, &podList, client.InNamespace(cluster.Namespace), inComponent); err != nil {
+		return nil, err
+	}
+	pods := map[string]*corev1.Pod{}
+	for i := range podList.Items {
+		pods[podList.Items[i].Name] = &podList.Items[i]
+	}
+	var svcList corev1.ServiceList
+	if err := r.List(ctx, &svcList, client.InNamespace(cluster.Namespace), inComponent); err != nil {
+		return nil, err
+	}
+	svcs := map[string]*corev1.Service{}
+	for i := range svcList.Items {
+		svcs[svcList.Items[i].Name] = &svcList.Items[i]
+	}
+
+	addrs := map[string]string{}
+	for i := range tikvs {
+		kv := &tikvs[i]
+		if kv.Status.ID != "" {
+			continue
+		}
+		pc := &common.PodContext{Cluster: cluster}
+		if kv.Spec.Server.Service != nil {
+			pc.ExternalAddress = common.ExternalAddress(svcs[common.InstanceExternalServiceName(kv)])
+			// The pod is not created until the external address is allocated
+			if pc.ExternalAddress == "" {
+				continue
+			}
+		}
+		if addr := tikv.StoreAddr(pc, kv, pods[kv.Name]); addr != "" {
+			addrs[kv.Name] = addr
+		}
+	}
+	return addrs, nil
+}
+
+// matchTiKV returns the name of the TiKV of a store by the store id.
+// TiKVs whose store id is not known yet are matched by their store addresses.
+func matchTiKV(tikvs []v1alpha1.TiKV, addrs map[string]string, store *pdapi.StoreInfo) (string, bool) {
+	id := strconv.FormatUint(store.Store.GetId(), 10)
+	for i := range tikvs {
+		if tikvs[i].Status.ID == id {
+			return tikvs[i].Name, true
+		}
+	}
+	for name, addr := range addrs {
+		if store.Store.GetAddress() == addr {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func setConsistentCondition(cluster *v1alpha1.Cluster, status *v1alpha1.ConsistencyStatus) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.ClusterCondConsistent,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: cluster.Generation,
+		Reason:             v1alpha1.ReasonConsistent,
+		Message:            "all stores and members in PD match CRs",
+	}
+	var msgs []string
+	if n := len(status.OrphanStores); n > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d stores without TiKV", n))
+	}
+	if n := len(status.TombstoneStores); n > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d tombstone stores", n))
+	}
+	if n := len(status.MissingStores); n > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d TiKVs without store", n))
+	}
+	if n := len(status.StaleMembers); n > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d members without PD", n))
+	}
+	if len(msgs) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v1alpha1.ReasonInconsistent
+		cond.Message = strings.Join(msgs, ", ")
+	}
+	meta.SetStatusCondition(&cluster.Status.Conditions, cond)
+}
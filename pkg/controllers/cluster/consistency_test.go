@@ -0,0 +1,163 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+func TestMatchTiKV(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tikvs := []v1alpha1.TiKV{
+		{ObjectMeta: metav1.ObjectMeta{Name: "tikv-tikv-0"}, Status: v1alpha1.TiKVStatus{StoreStatus: v1alpha1.StoreStatus{ID: "1"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tikv-tikv-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tikv-tikv-2"}},
+	}
+	addrs := map[string]string{
+		"tikv-tikv-1": "tikv-tikv-1.basic-tikv:20160",
+		"tikv-tikv-2": "10.0.0.2:30160",
+	}
+
+	tcs := []struct {
+		caseName string
+		id       uint64
+		addr     string
+		want     string
+	}{{
+		caseName: "by id",
+		id:       1,
+		addr:     "tikv-tikv-1.basic-tikv:20160",
+		want:     "tikv-tikv-0",
+	}, {
+		caseName: "by address",
+		id:       2,
+		addr:     "tikv-tikv-1.basic-tikv:20160",
+		want:     "tikv-tikv-1",
+	}, {
+		caseName: "by external address",
+		id:       3,
+		addr:     "10.0.0.2:30160",
+		want:     "tikv-tikv-2",
+	}, {
+		caseName: "address is a prefix of another address",
+		id:       4,
+		addr:     "tikv-tikv-1.basic-tikv:2016",
+	}, {
+		caseName: "store of another cluster",
+		id:       5,
+		addr:     "tikv-tikv-1.other-tikv:20160",
+	}}
+
+	for _, tc := range tcs {
+		store := &pdapi.StoreInfo{Store: &pdapi.MetaStore{Store: &metapb.Store{Id: tc.id, Address: tc.addr}}}
+		name, ok := matchTiKV(tikvs, addrs, store)
+		g.Expect(ok).To(Equal(tc.want != ""), tc.caseName)
+		g.Expect(name).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestStoreAddrs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	labels := map[string]string{
+		v1alpha1.LabelKeyCluster:   "basic",
+		v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
+	}
+	newPod := func(name, hostIP, podIP string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels},
+			Status:     corev1.PodStatus{HostIP: hostIP, PodIP: podIP},
+		}
+	}
+	newTiKV := func(name string, mutate func(*v1alpha1.TiKV)) v1alpha1.TiKV {
+		kv := v1alpha1.TiKV{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       v1alpha1.TiKVSpec{Cluster: v1alpha1.ClusterReference{Name: "basic"}},
+		}
+		if mutate != nil {
+			mutate(&kv)
+		}
+		return kv
+	}
+	nodePort := func(kv *v1alpha1.TiKV) {
+		kv.Spec.Server.Service = &v1alpha1.ExternalService{Type: corev1.ServiceTypeNodePort}
+	}
+	newService := func(name string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels},
+			Spec: corev1.ServiceSpec{
+				Type:  corev1.ServiceTypeNodePort,
+				Ports: []corev1.ServicePort{{Port: 20160, NodePort: 30160}},
+			},
+		}
+	}
+
+	tikvs := []v1alpha1.TiKV{
+		newTiKV("tikv-tikv-0", func(kv *v1alpha1.TiKV) { kv.Status.ID = "1" }),
+		newTiKV("tikv-tikv-1", nil),
+		newTiKV("tikv-tikv-2", nodePort),
+		// The node of the pod is not known yet
+		newTiKV("tikv-tikv-3", nodePort),
+		newTiKV("tikv-tikv-4", func(kv *v1alpha1.TiKV) { kv.Spec.Network.Mode = v1alpha1.NetworkModeHost }),
+		// The pod ip is not allocated yet
+		newTiKV("tikv-tikv-5", func(kv *v1alpha1.TiKV) { kv.Spec.Network.Mode = v1alpha1.NetworkModeHost }),
+		// The external service is not created yet
+		newTiKV("tikv-tikv-6", nodePort),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newPod("tikv-tikv-0", "10.0.0.1", "10.1.0.1"),
+		newPod("tikv-tikv-2", "10.0.0.2", "10.1.0.2"),
+		newPod("tikv-tikv-3", "", ""),
+		newPod("tikv-tikv-4", "10.0.0.4", "10.0.0.4"),
+		newPod("tikv-tikv-5", "10.0.0.5", ""),
+		newPod("tikv-tikv-6", "10.0.0.6", "10.1.0.6"),
+		newService("tikv-tikv-2-external"),
+		newService("tikv-tikv-3-external"),
+	).Build()
+
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "ns"}}
+	r := &ClusterReconciler{Client: c}
+	addrs, err := r.storeAddrs(context.Background(), cluster, tikvs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(addrs).To(Equal(map[string]string{
+		"tikv-tikv-1": "tikv-tikv-1.basic-tikv:20160",
+		"tikv-tikv-2": "10.0.0.2:30160",
+		"tikv-tikv-4": "10.0.0.4:20160",
+	}))
+
+	// Advertise addresses are fully qualified with the cluster domain
+	cluster.Spec.ClusterDomain = "cluster.local"
+	addrs, err = r.storeAddrs(context.Background(), cluster, tikvs[:2])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(addrs).To(Equal(map[string]string{
+		"tikv-tikv-1": "tikv-tikv-1.basic-tikv.ns.svc.cluster.local:20160",
+	}))
+}
@@ -15,9 +15,12 @@ package cluster
 
 import (
 	"context"
+	"sort"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -26,26 +29,33 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/pd"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
 )
 
 // ClusterReconciler reconciles a Cluster object
 type ClusterReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Log    logr.Logger
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// PDControl provides clients of PD to clean up leaked schedulers
+	PDControl pdapi.PDControlInterface
 }
 
 // Setup sets up the controller with the Manager.
-func Setup(mgr manager.Manager) error {
+func Setup(mgr manager.Manager, pdc pdapi.PDControlInterface) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Cluster{}).
 		Watches(&v1alpha1.PDGroup{}, handler.EnqueueRequestsFromMapFunc(enqueueForPDGroup)).
 		Watches(&v1alpha1.TiKVGroup{}, handler.EnqueueRequestsFromMapFunc(enqueueForTiKVGroup)).
 		WithOptions(controller.Options{}).
 		Complete(&ClusterReconciler{
-			Client: mgr.GetClient(),
-			Scheme: mgr.GetScheme(),
-			Log:    mgr.GetLogger().WithName("cluster"),
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Log:       mgr.GetLogger().WithName("cluster"),
+			Recorder:  mgr.GetEventRecorderFor("cluster"),
+			PDControl: pdc,
 		})
 }
 
@@ -82,13 +92,66 @@ func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Managed resources are frozen while the cluster is paused
+	if !cluster.Spec.Paused {
+		if err := r.reconcileMonitors(ctx, cluster); err != nil {
+			log.Error(err, "failed to reconcile monitors")
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, v1alpha1.ReasonReconcileFailed, "can't reconcile monitors: %v", err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// PD may be unavailable, e.g. the cluster is being created, the status is still updated
+	pdAvailable, err := r.resolvePD(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if pdAvailable {
+		if !cluster.Spec.Paused {
+			if err := r.cleanupEvictLeaderSchedulers(ctx, cluster); err != nil {
+				log.Error(err, "failed to clean up evict-leader schedulers")
+				r.Recorder.Eventf(cluster, corev1.EventTypeWarning, v1alpha1.ReasonPDAPIError, "can't clean up evict-leader schedulers: %v", err)
+			}
+		}
+		// Discrepancies are reported even if the cluster is paused, cleanup is skipped
+		if err := r.checkConsistency(ctx, cluster); err != nil {
+			log.Error(err, "failed to check consistency with PD")
+			r.Recorder.Eventf(cluster, corev1.EventTypeWarning, v1alpha1.ReasonPDAPIError, "can't check consistency with PD: %v", err)
+		}
+	}
+
 	// Update cluster status by aggregating status from all groups
 	if err := r.updateClusterStatus(ctx, cluster); err != nil {
 		log.Error(err, "failed to update cluster status")
+		r.Recorder.Eventf(cluster, corev1.EventTypeWarning, v1alpha1.ReasonReconcileFailed, "can't update status: %v", err)
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: schedulerCleanupInterval}, nil
+}
+
+// resolvePD sets the PD url of the cluster from the client port of the first PDGroup by name,
+// and returns whether some PD instances of the cluster are ready.
+// The url is kept if there is no PDGroup.
+func (r *ClusterReconciler) resolvePD(ctx context.Context, cluster *v1alpha1.Cluster) (bool, error) {
+	var pdGroupList v1alpha1.PDGroupList
+	if err := r.List(ctx, &pdGroupList, client.InNamespace(cluster.Namespace),
+		client.MatchingFields{"spec.cluster.name": cluster.Name}); err != nil {
+		return false, err
+	}
+	if len(pdGroupList.Items) > 0 {
+		sort.Slice(pdGroupList.Items, func(i, j int) bool {
+			return pdGroupList.Items[i].Name < pdGroupList.Items[j].Name
+		})
+		port := pd.ClientPort(&pdGroupList.Items[0].Spec.Template.Spec)
+		cluster.Status.PD = pdapi.PdClientURL(pdapi.Namespace(cluster.Namespace), cluster.Name, "http", port)
+	}
+	for _, pdg := range pdGroupList.Items {
+		if pdg.Status.ReadyReplicas > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (r *ClusterReconciler) updateClusterStatus(ctx context.Context, cluster *v1alpha1.Cluster) error {
@@ -135,10 +198,5 @@ func (r *ClusterReconciler) updateClusterStatus(ctx context.Context, cluster *v1
 	cluster.Status.Components = components
 	cluster.Status.ObservedGeneration = cluster.Generation
 
-	// Set PD URL if available
-	if len(pdGroupList.Items) > 0 {
-		cluster.Status.PD = "http://pd:2379"
-	}
-
 	return r.Status().Update(ctx, cluster)
 }
@@ -0,0 +1,156 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+)
+
+// monitoredComponents are components scraped by Prometheus and names of their metrics ports
+var monitoredComponents = []struct {
+	component string
+	port      string
+}{
+	{component: v1alpha1.LabelValComponentPD, port: v1alpha1.PDPortNameClient},
+	{component: v1alpha1.LabelValComponentTiKV, port: v1alpha1.TiKVPortNameStatus},
+}
+
+// reconcileMonitors ensures a monitor of the configured kind for each component.
+// Monitors of the other kind, or of a cluster without monitoring, are deleted.
+// Nothing is done for a kind whose CRD is not installed, pods are annotated by instance controllers instead.
+func (r *ClusterReconciler) reconcileMonitors(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	for _, kind := range []v1alpha1.MonitorKind{v1alpha1.MonitorKindPodMonitor, v1alpha1.MonitorKindServiceMonitor} {
+		installed, err := common.IsMonitorInstalled(r.RESTMapper(), kind)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			continue
+		}
+
+		enabled := cluster.Spec.Monitoring != nil && common.MonitorKind(cluster.Spec.Monitoring) == kind
+		for _, c := range monitoredComponents {
+			if !enabled {
+				if err := r.deleteMonitor(ctx, cluster, kind, c.component); err != nil {
+					return err
+				}
+				continue
+			}
+
+			monitor := newMonitor(cluster, kind, c.component, c.port)
+			if err := controllerutil.SetControllerReference(cluster, monitor, r.Scheme); err != nil {
+				return err
+			}
+			applied, err := common.Apply(ctx, r.Client, r.Recorder, cluster, monitor)
+			if err != nil {
+				return err
+			}
+			if applied {
+				r.Log.Info("Monitor applied", "kind", kind, "name", monitor.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// deleteMonitor deletes the monitor of a component if it's controlled by the cluster
+func (r *ClusterReconciler) deleteMonitor(ctx context.Context, cluster *v1alpha1.Cluster, kind v1alpha1.MonitorKind, component string) error {
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(common.MonitoringGroupVersion.WithKind(string(kind)))
+	if err := r.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      monitorName(cluster, component),
+	}, monitor); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(monitor, cluster) {
+		return nil
+	}
+	if err := r.Delete(ctx, monitor); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	r.Log.Info("Monitor deleted", "kind", kind, "name", monitor.GetName())
+	return nil
+}
+
+func monitorName(cluster *v1alpha1.Cluster, component string) string {
+	return fmt.Sprintf("%s-%s", cluster.Name, component)
+}
+
+// newMonitor returns a PodMonitor or ServiceMonitor of a component.
+// Both select resources by the labels of the component and attach the cluster/group/instance labels of pods to targets.
+// A ServiceMonitor only selects the headless service, external services expose the same pods.
+func newMonitor(cluster *v1alpha1.Cluster, kind v1alpha1.MonitorKind, component, port string) *unstructured.Unstructured {
+	endpoint := map[string]any{
+		"port": port,
+		"path": common.MetricsPath,
+	}
+	if cluster.Spec.Monitoring.Interval != "" {
+		endpoint["interval"] = cluster.Spec.Monitoring.Interval
+	}
+
+	matchLabels := map[string]any{
+		v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
+		v1alpha1.LabelKeyCluster:   cluster.Name,
+		v1alpha1.LabelKeyComponent: component,
+	}
+	if kind == v1alpha1.MonitorKindServiceMonitor {
+		matchLabels[v1alpha1.LabelKeyHeadlessService] = "true"
+	}
+	spec := map[string]any{
+		"selector": map[string]any{
+			"matchLabels": matchLabels,
+		},
+		"namespaceSelector": map[string]any{
+			"matchNames": []any{cluster.Namespace},
+		},
+		"podTargetLabels": []any{
+			v1alpha1.LabelKeyCluster,
+			v1alpha1.LabelKeyComponent,
+			v1alpha1.LabelKeyGroup,
+			v1alpha1.LabelKeyInstance,
+		},
+	}
+	switch kind {
+	case v1alpha1.MonitorKindPodMonitor:
+		spec["podMetricsEndpoints"] = []any{endpoint}
+	case v1alpha1.MonitorKindServiceMonitor:
+		spec["endpoints"] = []any{endpoint}
+	}
+
+	labels := map[string]string{}
+	for k, v := range cluster.Spec.Monitoring.Labels {
+		labels[k] = v
+	}
+	labels[v1alpha1.LabelKeyManagedBy] = v1alpha1.LabelValManagedByOperator
+	labels[v1alpha1.LabelKeyCluster] = cluster.Name
+	labels[v1alpha1.LabelKeyComponent] = component
+
+	monitor := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	monitor.SetGroupVersionKind(common.MonitoringGroupVersion.WithKind(string(kind)))
+	monitor.SetNamespace(cluster.Namespace)
+	monitor.SetName(monitorName(cluster, component))
+	monitor.SetLabels(labels)
+	return monitor
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestNewMonitorSelector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		kind     v1alpha1.MonitorKind
+		want     map[string]string
+	}{{
+		caseName: "pod monitor selects all pods of the component",
+		kind:     v1alpha1.MonitorKindPodMonitor,
+		want: map[string]string{
+			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
+			v1alpha1.LabelKeyCluster:   "basic",
+			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentPD,
+		},
+	}, {
+		caseName: "service monitor only selects the headless service",
+		kind:     v1alpha1.MonitorKindServiceMonitor,
+		want: map[string]string{
+			v1alpha1.LabelKeyManagedBy:       v1alpha1.LabelValManagedByOperator,
+			v1alpha1.LabelKeyCluster:         "basic",
+			v1alpha1.LabelKeyComponent:       v1alpha1.LabelValComponentPD,
+			v1alpha1.LabelKeyHeadlessService: "true",
+		},
+	}}
+
+	for _, tc := range tcs {
+		cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "ns"}}
+		cluster.Spec.Monitoring = &v1alpha1.Monitoring{}
+		monitor := newMonitor(cluster, tc.kind, v1alpha1.LabelValComponentPD, v1alpha1.PDPortNameClient)
+		selector, found, err := unstructured.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(found).To(BeTrue(), tc.caseName)
+		g.Expect(selector).To(Equal(tc.want), tc.caseName)
+	}
+}
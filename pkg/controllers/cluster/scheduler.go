@@ -0,0 +1,80 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+// schedulerCleanupInterval is the interval to clean up leaked evict-leader schedulers and check consistency of a cluster
+const schedulerCleanupInterval = 5 * time.Minute
+
+// cleanupEvictLeaderSchedulers removes evict-leader schedulers of stores which are not legitimately evicting,
+// e.g. the operator crashed before ending the eviction or the TiKV is deleted.
+// A store is legitimately evicting if its TiKV reports the LeadersEvicted condition as True or Evicting.
+func (r *ClusterReconciler) cleanupEvictLeaderSchedulers(ctx context.Context, cluster *v1alpha1.Cluster) error {
+	pdc := common.PDClient(r.PDControl, cluster)
+	schedulers, err := pdc.GetEvictLeaderSchedulers()
+	if err != nil {
+		return err
+	}
+	if len(schedulers) == 0 {
+		return nil
+	}
+
+	var tikvList v1alpha1.TiKVList
+	if err := r.List(ctx, &tikvList, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{v1alpha1.LabelKeyCluster: cluster.Name}); err != nil {
+		return err
+	}
+	evicting := map[uint64]struct{}{}
+	for i := range tikvList.Items {
+		tikv := &tikvList.Items[i]
+		cond := meta.FindStatusCondition(tikv.Status.Conditions, v1alpha1.TiKVCondLeadersEvicted)
+		if cond == nil || (cond.Status != metav1.ConditionTrue && cond.Reason != v1alpha1.ReasonEvicting) {
+			continue
+		}
+		if id, err := strconv.ParseUint(tikv.Status.ID, 10, 64); err == nil {
+			evicting[id] = struct{}{}
+		}
+	}
+
+	for _, scheduler := range schedulers {
+		storeID, ok := pdapi.ParseEvictLeaderSchedulerStoreID(scheduler)
+		if !ok {
+			continue
+		}
+		if _, ok := evicting[storeID]; ok {
+			continue
+		}
+		if err := pdc.EndEvictLeader(storeID); err != nil {
+			return err
+		}
+		r.Log.Info("removed leaked evict-leader scheduler", "cluster", cluster.Name, "scheduler", scheduler)
+		r.Recorder.Eventf(cluster, corev1.EventTypeNormal, v1alpha1.ReasonLeakedSchedulerRemoved,
+			"evict-leader scheduler of store %d is leaked and removed", storeID)
+	}
+	return nil
+}
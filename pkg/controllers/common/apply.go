@@ -0,0 +1,181 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	policyv1ac "k8s.io/client-go/applyconfigurations/policy/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+// FieldManager is the field manager of all resources applied by the operator
+const FieldManager = "tikv-operator"
+
+// Apply applies the desired object with server-side apply under FieldManager.
+// Fields of the object owned by other managers, e.g. injected sidecars or labels added by other tools, are kept.
+// The object is only applied if it's not found or the fields owned by FieldManager drift from the desired ones.
+// A conflict with another manager is not forced, it's reported as a Warning event of the owner and returned.
+// Fields of a resource created by an older operator without server-side apply are transferred to FieldManager first.
+// It returns whether the object is applied, and desired is updated to the applied object.
+func Apply(ctx context.Context, c client.Client, recorder record.EventRecorder, owner, desired client.Object) (bool, error) {
+	obj, err := toApplyObject(c.Scheme(), desired)
+	if err != nil {
+		return false, err
+	}
+
+	current := desired.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(desired), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+	} else {
+		if err := upgradeManagedFields(ctx, c, current); err != nil {
+			return false, fmt.Errorf("can't upgrade managed fields of %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		drifted, err := isDrifted(current, obj)
+		if err != nil {
+			return false, err
+		}
+		if !drifted {
+			return false, runtime.DefaultUnstructuredConverter.FromUnstructured(mustToUnstructured(current), desired)
+		}
+	}
+
+	if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager)); err != nil {
+		if errors.IsConflict(err) {
+			recorder.Eventf(owner, corev1.EventTypeWarning, v1alpha1.ReasonApplyConflict,
+				"can't apply %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		return false, fmt.Errorf("can't apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	return true, runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, desired)
+}
+
+// legacyFieldManager is the field manager of updates made by the operator without server-side apply,
+// e.g. resources created by CreateOrUpdate of older versions.
+// The API server derives it from the default user agent, i.e. the name of the binary.
+var legacyFieldManager = strings.SplitN(rest.DefaultKubernetesUserAgent(), "/", 2)[0]
+
+// upgradeManagedFields transfers fields owned by Update operations of legacyFieldManager to FieldManager once,
+// otherwise applying them conflicts forever. Only kinds created by older operators are upgraded,
+// other updates of the operator, e.g. finalizers and the offline flag of instances, are not owned by FieldManager.
+func upgradeManagedFields(ctx context.Context, c client.Client, current client.Object) error {
+	switch current.(type) {
+	case *corev1.Pod, *corev1.ConfigMap, *corev1.Service, *corev1.PersistentVolumeClaim:
+	default:
+		return nil
+	}
+	patch, err := csaupgrade.UpgradeManagedFieldsPatch(current, sets.New(legacyFieldManager), FieldManager)
+	if err != nil || patch == nil {
+		return err
+	}
+	return c.Patch(ctx, current, client.RawPatch(types.JSONPatchType, patch))
+}
+
+// toApplyObject converts a typed object to the unstructured form sent by server-side apply
+func toApplyObject(scheme *runtime.Scheme, obj client.Object) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	// Status is never applied and server managed metadata must not be owned
+	delete(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+	return u, nil
+}
+
+// isDrifted returns whether the fields of current owned by FieldManager differ from the desired ones.
+// Kinds whose owned fields can't be extracted are always considered drifted,
+// applying an unchanged object is a no-op in the server.
+func isDrifted(current client.Object, desired *unstructured.Unstructured) (bool, error) {
+	var owned any
+	var err error
+	switch obj := current.(type) {
+	case *corev1.Pod:
+		owned, err = corev1ac.ExtractPod(obj, FieldManager)
+	case *corev1.ConfigMap:
+		owned, err = corev1ac.ExtractConfigMap(obj, FieldManager)
+	case *corev1.Service:
+		owned, err = corev1ac.ExtractService(obj, FieldManager)
+	case *corev1.PersistentVolumeClaim:
+		owned, err = corev1ac.ExtractPersistentVolumeClaim(obj, FieldManager)
+	case *policyv1.PodDisruptionBudget:
+		owned, err = policyv1ac.ExtractPodDisruptionBudget(obj, FieldManager)
+	default:
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Compare in the json form to ignore differences of go types
+	ownedMap, err := toJSONMap(owned)
+	if err != nil {
+		return false, err
+	}
+	desiredMap, err := toJSONMap(desired.Object)
+	if err != nil {
+		return false, err
+	}
+	return !reflect.DeepEqual(ownedMap, desiredMap), nil
+}
+
+func toJSONMap(obj any) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func mustToUnstructured(obj client.Object) map[string]any {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		// All objects are api types which can always be converted
+		panic(err)
+	}
+	return content
+}
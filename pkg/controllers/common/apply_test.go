@@ -0,0 +1,78 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestUpgradeManagedFields(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	legacy := []metav1.ManagedFieldsEntry{{
+		Manager:    legacyFieldManager,
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{".":{},"f:app":{}}}}`)},
+	}}
+
+	tcs := []struct {
+		caseName    string
+		obj         client.Object
+		wantManager string
+		wantOp      metav1.ManagedFieldsOperationType
+	}{{
+		caseName:    "resource created by an older operator",
+		obj:         &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns", ManagedFields: legacy}},
+		wantManager: FieldManager,
+		wantOp:      metav1.ManagedFieldsOperationApply,
+	}, {
+		caseName:    "instances keep updates of the operator",
+		obj:         &v1alpha1.TiKV{ObjectMeta: metav1.ObjectMeta{Name: "tikv", Namespace: "ns", ManagedFields: legacy}},
+		wantManager: legacyFieldManager,
+		wantOp:      metav1.ManagedFieldsOperationUpdate,
+	}}
+
+	for _, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.obj).Build()
+		current := tc.obj.DeepCopyObject().(client.Object)
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(tc.obj), current)).To(Succeed(), tc.caseName)
+
+		g.Expect(upgradeManagedFields(context.Background(), c, current)).To(Succeed(), tc.caseName)
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(tc.obj), current)).To(Succeed(), tc.caseName)
+		managedFields := current.GetManagedFields()
+		g.Expect(managedFields).To(HaveLen(1), tc.caseName)
+		g.Expect(managedFields[0].Manager).To(Equal(tc.wantManager), tc.caseName)
+		g.Expect(managedFields[0].Operation).To(Equal(tc.wantOp), tc.caseName)
+
+		// Upgraded fields are not upgraded again
+		g.Expect(upgradeManagedFields(context.Background(), c, current)).To(Succeed(), tc.caseName)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+// Group is implemented by all component groups, e.g. PDGroup and TiKVGroup
+type Group interface {
+	client.Object
+	ClusterName() string
+	Component() string
+	GetReplicas() int32
+	IsSuspended() bool
+	GetPVCRetentionPolicy() v1alpha1.PVCRetentionPolicy
+	GetScaleInInstances() []string
+	GetCommonStatus() *v1alpha1.CommonStatus
+	GetGroupStatus() *v1alpha1.GroupStatus
+}
+
+// Instance is implemented by all component instances, e.g. PD and TiKV
+type Instance interface {
+	client.Object
+	ClusterName() string
+	Component() string
+	GetCommonStatus() *v1alpha1.CommonStatus
+	GetConfig() string
+	GetVolumes() []v1alpha1.Volume
+	GetResources() v1alpha1.ResourceRequirements
+	GetNetwork() *v1alpha1.Network
+}
+
+// Component provides the types of a component
+type Component[G Group, I Instance] interface {
+	// NewGroup returns an empty group
+	NewGroup() G
+	// NewInstance returns an empty instance
+	NewInstance() I
+	// ListInstances lists instances matching the labels
+	ListInstances(ctx context.Context, c client.Client, ns string, labels client.MatchingLabels) ([]I, error)
+	// MinReadySeconds returns how long an instance should be ready before it's counted as available
+	MinReadySeconds() int32
+}
+
+// InstanceAdapter provides the component specific parts of an instance controller
+type InstanceAdapter[G Group, I Instance] interface {
+	Component[G, I]
+	// Service returns the headless service shared by all instances of the component in a cluster
+	Service(instance I) *corev1.Service
+	// ExternalService returns the service of the instance accessed from outside of the Kubernetes cluster.
+	// It's nil if the instance has no external service.
+	ExternalService(instance I) *corev1.Service
+	// Container returns the main container of the instance pod, it runs the start script.
+	// Resources and data volume mounts are added by the framework.
+	Container(instance I) corev1.Container
+	// StartScript returns the script which starts the server of the instance.
+	// Settings managed by the operator, e.g. listen and advertised addresses, are passed by it.
+	StartScript(pc *PodContext, instance I) *StartScript
+	// MetricsPort returns the container port which serves metrics
+	MetricsPort(instance I) int32
+	// DefaultMountPath returns the mount path of a volume if it's not specified.
+	// It's empty if the mount type is not supported by the component.
+	DefaultMountPath(t v1alpha1.VolumeMountType) string
+	// Config returns the content of the config file of the instance.
+	// Settings derived from the instance, e.g. dirs of mounted volumes, are added to the user-specified config.
+	Config(instance I) (string, error)
+	// SyncPD makes the changes of an instance in PD, e.g. deletes the store of an offline TiKV.
+	// It's not called while the cluster is paused or the group is suspended.
+	// It returns true if the changes are still in progress, the instance is checked again later.
+	SyncPD(pdc pdapi.PDClient, instance I) (bool, error)
+	// IsStoppable returns whether the pod of an instance can be stopped when its group is suspended,
+	// and a message if it can't, e.g. leaders of the TiKV store are still being evicted.
+	IsStoppable(instance I) (bool, string)
+	// UpdateStatus sets the component specific status of an instance from PD, it must not change anything in PD.
+	// The pod context provides what the instance advertises to PD, and events are recorded for transitions in PD.
+	// It returns whether the instance is healthy in PD's view and a message if it's not.
+	UpdateStatus(pdc pdapi.PDClient, recorder record.EventRecorder, pc *PodContext, instance I, pod *corev1.Pod) (bool, string, error)
+}
+
+// GroupAdapter provides the component specific parts of a group controller
+type GroupAdapter[G Group, I Instance] interface {
+	Component[G, I]
+	// ListGroups lists groups of a cluster
+	ListGroups(ctx context.Context, c client.Client, ns, cluster string) ([]G, error)
+	// Template returns the instance template of a group, its hash is the update revision
+	Template(group G) any
+	// NewInstanceFromGroup returns the desired instance of the group.
+	// Labels and owner references are set by the framework.
+	NewInstanceFromGroup(group G, name string) I
+	// PreDelete is called before an instance is deleted on scale in
+	PreDelete(ctx context.Context, c client.Client, recorder record.EventRecorder, group G, instance I) error
+	// MaxUnavailable returns how many instances of all groups of the component in a cluster can be voluntarily
+	// disrupted at the same time. It's the maxUnavailable of the PodDisruptionBudget of the component.
+	MaxUnavailable(pdc pdapi.PDClient, groups []G, instances []I) (int32, error)
+	// MinReplicas returns the min replicas the group can be scaled in to, and a message explaining the limit
+	MinReplicas(ctx context.Context, c client.Client, pdc pdapi.PDClient, group G) (int32, string, error)
+	// ReplaceConcurrency returns how many instances whose volumes can't be changed in place
+	// are replaced at the same time. Such instances are not replaced if it's 0.
+	ReplaceConcurrency(group G) int32
+	// IsDeletable returns whether an instance prepared by PreDelete can be deleted, e.g. its store is removed
+	IsDeletable(instance I) bool
+	// IsPreDeleted returns whether an instance has been prepared by PreDelete, e.g. its store is being offlined.
+	// Such an instance is no longer counted as a replica and is deleted once it's deletable.
+	IsPreDeleted(instance I) bool
+	// ExternalService returns the service of the group accessed from outside of the Kubernetes cluster.
+	// It's nil if the group has no external service.
+	ExternalService(group G) *corev1.Service
+}
+
+// PodContext provides what the pod of an instance depends on besides the instance
+type PodContext struct {
+	// Cluster provides the address of PD
+	Cluster *v1alpha1.Cluster
+	// ExternalAddress is the address of the external service of the instance, it's advertised if not empty
+	ExternalAddress string
+	// ConfigHash is the hash of the rendered config and start script, it's empty if they are not rendered
+	ConfigHash string
+}
+
+// InstanceLabels returns the labels of an instance and its managed resources
+func InstanceLabels(cluster, component, group, instance string) map[string]string {
+	return map[string]string{
+		v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
+		v1alpha1.LabelKeyCluster:   cluster,
+		v1alpha1.LabelKeyComponent: component,
+		v1alpha1.LabelKeyGroup:     group,
+		v1alpha1.LabelKeyInstance:  instance,
+	}
+}
+
+// Hash returns a stable short hash of an object
+func Hash(obj any) string {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		// All hashed objects are api types which can always be marshaled
+		panic(err)
+	}
+	h := fnv.New32a()
+	h.Write(data)
+	return rand.SafeEncodeString(fmt.Sprint(h.Sum32()))
+}
@@ -0,0 +1,251 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+// SetInstanceSuspendedCondition sets the Suspended condition of an instance.
+// An instance is suspended only after its pod is gone.
+func SetInstanceSuspendedCondition(conds *[]metav1.Condition, generation int64, suspend, podExists bool) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondSuspended,
+		Status:             metav1.ConditionFalse,
+		Reason:             v1alpha1.ReasonUnsuspended,
+		Message:            "instance is not suspended",
+		ObservedGeneration: generation,
+	}
+	switch {
+	case suspend && podExists:
+		cond.Reason = v1alpha1.ReasonSuspending
+		cond.Message = "pod is being deleted"
+	case suspend:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonSuspended
+		cond.Message = "pod is deleted, pvcs are retained"
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// SetGroupSuspendedCondition sets the Suspended condition of a group.
+// A group is suspended only after all of its instances are suspended.
+func SetGroupSuspendedCondition(conds *[]metav1.Condition, generation int64, suspend bool, instanceConds [][]metav1.Condition) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondSuspended,
+		Status:             metav1.ConditionFalse,
+		Reason:             v1alpha1.ReasonUnsuspended,
+		Message:            "group is not suspended",
+		ObservedGeneration: generation,
+	}
+	if suspend {
+		suspended := 0
+		for _, c := range instanceConds {
+			if meta.IsStatusConditionTrue(c, v1alpha1.CondSuspended) {
+				suspended++
+			}
+		}
+		if suspended == len(instanceConds) {
+			cond.Status = metav1.ConditionTrue
+			cond.Reason = v1alpha1.ReasonSuspended
+			cond.Message = "all instances are suspended"
+		} else {
+			cond.Reason = v1alpha1.ReasonSuspending
+			cond.Message = "not all instances are suspended"
+		}
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// SetInstanceRunningCondition sets the Running condition of an instance from its pod
+func SetInstanceRunningCondition(conds *[]metav1.Condition, generation int64, pod *corev1.Pod) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondRunning,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	if !setPodNotRunningReason(&cond, pod) {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonRunning
+		cond.Message = "pod is running"
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// SetInstanceReadyCondition sets the Ready condition of an instance.
+// An instance is ready only if its pod is ready and it's healthy in PD's view,
+// i.e. the store is Serving or the member is healthy.
+func SetInstanceReadyCondition(conds *[]metav1.Condition, generation int64, pod *corev1.Pod, healthy bool, unhealthyMsg string) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondReady,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case setPodNotRunningReason(&cond, pod):
+	case !IsPodReady(pod):
+		cond.Reason = v1alpha1.ReasonPodNotReady
+		cond.Message = "pod is not ready"
+	case !healthy:
+		cond.Reason = v1alpha1.ReasonInstanceNotHealthy
+		cond.Message = unhealthyMsg
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonReady
+		cond.Message = "instance is ready"
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// setPodNotRunningReason sets the reason of a condition if the pod is not running
+func setPodNotRunningReason(cond *metav1.Condition, pod *corev1.Pod) bool {
+	switch {
+	case pod == nil:
+		cond.Reason = v1alpha1.ReasonPodNotCreated
+		cond.Message = "pod is not created"
+	case !pod.DeletionTimestamp.IsZero():
+		cond.Reason = v1alpha1.ReasonPodTerminating
+		cond.Message = "pod is terminating"
+	case pod.Status.Phase != corev1.PodRunning:
+		cond.Reason = v1alpha1.ReasonPodNotRunning
+		cond.Message = fmt.Sprintf("pod is %s", pod.Status.Phase)
+	default:
+		return false
+	}
+	return true
+}
+
+// IsInstanceAvailable returns whether an instance has been ready for at least minReadySeconds.
+// If the instance is ready but not available yet, the remaining duration is returned.
+func IsInstanceAvailable(conds []metav1.Condition, minReadySeconds int32, now time.Time) (bool, time.Duration) {
+	cond := meta.FindStatusCondition(conds, v1alpha1.CondReady)
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		return false, 0
+	}
+	availableAt := cond.LastTransitionTime.Add(time.Duration(minReadySeconds) * time.Second)
+	if now.Before(availableAt) {
+		return false, availableAt.Sub(now)
+	}
+	return true, 0
+}
+
+// SetInstanceSyncedCondition sets the Synced condition of an instance.
+// An instance is synced if its pod is up to date, or deleted if the instance is suspended.
+// The pod is outdated if its spec or config differs from the expected one.
+func SetInstanceSyncedCondition(conds *[]metav1.Condition, generation int64, suspend bool, pod *corev1.Pod, outdated bool) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondSynced,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case suspend && pod != nil:
+		cond.Reason = v1alpha1.ReasonPodNotDeleted
+		cond.Message = "pod of the suspended instance is not deleted"
+	case suspend:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonSynced
+		cond.Message = "pod of the suspended instance is deleted"
+	case pod == nil || outdated:
+		cond.Reason = v1alpha1.ReasonPodNotUpToDate
+		cond.Message = "pod is not up to date"
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonSynced
+		cond.Message = "pod is up to date"
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// IsPodOutdated returns whether the pod of an instance is observed to be outdated,
+// i.e. it's waiting to be recreated by the group.
+func IsPodOutdated(instance Instance) bool {
+	cond := meta.FindStatusCondition(instance.GetCommonStatus().Conditions, v1alpha1.CondSynced)
+	return cond != nil && cond.Status == metav1.ConditionFalse && cond.Reason == v1alpha1.ReasonPodNotUpToDate
+}
+
+// IsPodSynced returns whether the pod of an instance is observed to be up to date with the current spec
+func IsPodSynced(instance Instance) bool {
+	cond := meta.FindStatusCondition(instance.GetCommonStatus().Conditions, v1alpha1.CondSynced)
+	return cond != nil && cond.Status == metav1.ConditionTrue && cond.ObservedGeneration == instance.GetGeneration()
+}
+
+// SetGroupReadyCondition sets the Ready condition of a group
+func SetGroupReadyCondition(conds *[]metav1.Condition, generation int64, replicas, readyReplicas int32) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonReady,
+		Message:            "all instances are ready",
+		ObservedGeneration: generation,
+	}
+	if readyReplicas < replicas {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v1alpha1.ReasonNotAllInstancesReady
+		cond.Message = fmt.Sprintf("%d/%d instances are ready", readyReplicas, replicas)
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// SetGroupSyncedCondition sets the Synced condition of a group
+func SetGroupSyncedCondition(conds *[]metav1.Condition, generation int64, synced bool) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonSynced,
+		Message:            "all instances are up to date",
+		ObservedGeneration: generation,
+	}
+	if !synced {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v1alpha1.ReasonNotAllInstancesUpToDate
+		cond.Message = "not all instances are up to date"
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// SetGroupScaleInLimitedCondition sets the ScaleInLimited condition of a group.
+// The group is limited if the message, which explains why, is not empty.
+func SetGroupScaleInLimitedCondition(conds *[]metav1.Condition, generation int64, msg string) {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondScaleInLimited,
+		Status:             metav1.ConditionFalse,
+		Reason:             v1alpha1.ReasonScaleInNotLimited,
+		Message:            "scale in is not limited",
+		ObservedGeneration: generation,
+	}
+	if msg != "" {
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = v1alpha1.ReasonBelowMinReplicas
+		cond.Message = msg
+	}
+	meta.SetStatusCondition(conds, cond)
+}
+
+// IsPodReady returns whether the PodReady condition of a pod is true
+func IsPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestSetInstanceReadyCondition(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	readyPod := &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	notReadyPod := readyPod.DeepCopy()
+	notReadyPod.Status.Conditions = nil
+	pendingPod := readyPod.DeepCopy()
+	pendingPod.Status.Phase = corev1.PodPending
+
+	tcs := []struct {
+		caseName   string
+		pod        *corev1.Pod
+		healthy    bool
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{{
+		caseName:   "pod is not created",
+		wantStatus: metav1.ConditionFalse,
+		wantReason: v1alpha1.ReasonPodNotCreated,
+	}, {
+		caseName:   "pod is pending",
+		pod:        pendingPod,
+		healthy:    true,
+		wantStatus: metav1.ConditionFalse,
+		wantReason: v1alpha1.ReasonPodNotRunning,
+	}, {
+		caseName:   "pod is not ready",
+		pod:        notReadyPod,
+		healthy:    true,
+		wantStatus: metav1.ConditionFalse,
+		wantReason: v1alpha1.ReasonPodNotReady,
+	}, {
+		caseName:   "instance is not healthy in PD",
+		pod:        readyPod,
+		wantStatus: metav1.ConditionFalse,
+		wantReason: v1alpha1.ReasonInstanceNotHealthy,
+	}, {
+		caseName:   "ready",
+		pod:        readyPod,
+		healthy:    true,
+		wantStatus: metav1.ConditionTrue,
+		wantReason: v1alpha1.ReasonReady,
+	}}
+
+	for _, tc := range tcs {
+		var conds []metav1.Condition
+		SetInstanceReadyCondition(&conds, 1, tc.pod, tc.healthy, "store is Removing")
+		cond := meta.FindStatusCondition(conds, v1alpha1.CondReady)
+		g.Expect(cond).NotTo(BeNil(), tc.caseName)
+		g.Expect(cond.Status).To(Equal(tc.wantStatus), tc.caseName)
+		g.Expect(cond.Reason).To(Equal(tc.wantReason), tc.caseName)
+	}
+}
+
+func TestIsInstanceAvailable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Now()
+	tcs := []struct {
+		caseName      string
+		conds         []metav1.Condition
+		wantAvailable bool
+		wantRemaining time.Duration
+	}{{
+		caseName: "no ready condition",
+	}, {
+		caseName: "not ready",
+		conds: []metav1.Condition{{
+			Type:               v1alpha1.CondReady,
+			Status:             metav1.ConditionFalse,
+			LastTransitionTime: metav1.NewTime(now.Add(-time.Minute)),
+		}},
+	}, {
+		caseName: "ready recently",
+		conds: []metav1.Condition{{
+			Type:               v1alpha1.CondReady,
+			Status:             metav1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(now.Add(-2 * time.Second)),
+		}},
+		wantRemaining: 3 * time.Second,
+	}, {
+		caseName: "ready for min ready seconds",
+		conds: []metav1.Condition{{
+			Type:               v1alpha1.CondReady,
+			Status:             metav1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(now.Add(-5 * time.Second)),
+		}},
+		wantAvailable: true,
+	}}
+
+	for _, tc := range tcs {
+		available, remaining := IsInstanceAvailable(tc.conds, 5, now)
+		g.Expect(available).To(Equal(tc.wantAvailable), tc.caseName)
+		g.Expect(remaining).To(BeNumerically("~", tc.wantRemaining, time.Second), tc.caseName)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/task"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+const (
+	// hostIPEnv is the env var of the node ip, it's referenced by the external address of a NodePort service
+	hostIPEnv = "HOST_IP"
+	// podIPEnv is the env var of the pod ip, it's advertised if the pod uses the network of its node
+	podIPEnv = "POD_IP"
+)
+
+// ExpandPodEnv expands the ips referenced by an address advertised by a pod, so it's the address seen by PD.
+// It returns false if a referenced ip is not known yet, e.g. the pod is not scheduled.
+func ExpandPodEnv(addr string, pod *corev1.Pod) (string, bool) {
+	var hostIP, podIP string
+	if pod != nil {
+		hostIP = pod.Status.HostIP
+		podIP = pod.Status.PodIP
+	}
+	for _, env := range []struct{ name, value string }{
+		{hostIPEnv, hostIP},
+		{podIPEnv, podIP},
+	} {
+		ref := "${" + env.name + "}"
+		if !strings.Contains(addr, ref) {
+			continue
+		}
+		if env.value == "" {
+			return "", false
+		}
+		addr = strings.ReplaceAll(addr, ref, env.value)
+	}
+	return addr, true
+}
+
+// GroupExternalServiceName returns the name of the external service of a group
+func GroupExternalServiceName(group Group) string {
+	return fmt.Sprintf("%s-%s-external", group.GetName(), group.Component())
+}
+
+// InstanceExternalServiceName returns the name of the external service of an instance
+func InstanceExternalServiceName(instance client.Object) string {
+	return fmt.Sprintf("%s-external", instance.GetName())
+}
+
+// NewExternalService returns a service of the spec which exposes ports of the selected pods
+func NewExternalService(spec *v1alpha1.ExternalService, name, namespace string,
+	labels, selector map[string]string, ports []corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: spec.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                     spec.Type,
+			Selector:                 selector,
+			Ports:                    ports,
+			LoadBalancerSourceRanges: spec.LoadBalancerSourceRanges,
+			ExternalTrafficPolicy:    spec.ExternalTrafficPolicy,
+		},
+	}
+}
+
+// NewGroupExternalService returns a service of the spec which exposes ports of all pods of the group
+func NewGroupExternalService(group Group, spec *v1alpha1.ExternalService, ports []corev1.ServicePort) *corev1.Service {
+	selector := map[string]string{
+		v1alpha1.LabelKeyCluster:   group.ClusterName(),
+		v1alpha1.LabelKeyComponent: group.Component(),
+		v1alpha1.LabelKeyGroup:     group.GetName(),
+	}
+	labels := map[string]string{v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator}
+	for k, v := range selector {
+		labels[k] = v
+	}
+	return NewExternalService(spec, GroupExternalServiceName(group), group.GetNamespace(), labels, selector, ports)
+}
+
+// ExternalAddress returns the address of the first port of an external service reachable from outside.
+// The node ip of a NodePort service is a shell variable expanded by the start script.
+// It's empty if the address is not allocated yet, e.g. the load balancer is being provisioned.
+func ExternalAddress(svc *corev1.Service) string {
+	if svc == nil || len(svc.Spec.Ports) == 0 {
+		return ""
+	}
+	port := svc.Spec.Ports[0]
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeNodePort:
+		if port.NodePort == 0 {
+			return ""
+		}
+		host := fmt.Sprintf("${%s}", hostIPEnv)
+		if len(svc.Spec.IPFamilies) > 0 && svc.Spec.IPFamilies[0] == corev1.IPv6Protocol {
+			// The node ip is only known at runtime, it's of the primary ip family of the service
+			host = "[" + host + "]"
+		}
+		return fmt.Sprintf("%s:%d", host, port.NodePort)
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			host := ingress.IP
+			if host == "" {
+				host = ingress.Hostname
+			}
+			if host != "" {
+				return pdapi.JoinHostPort(host, port.Port)
+			}
+		}
+	}
+	return ""
+}
+
+// deleteOwnedService deletes a service if it's controlled by the owner.
+// It returns whether the service is deleted.
+func deleteOwnedService(ctx context.Context, c client.Client, owner client.Object, name string) (bool, error) {
+	svc := &corev1.Service{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}, svc); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(svc, owner) {
+		return false, nil
+	}
+	if err := c.Delete(ctx, svc); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// TaskExternalService applies the external service of the group, it's deleted if not specified
+func (r *GroupReconciler[G, I]) TaskExternalService(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("ExternalService", func(ctx context.Context) task.Result {
+		group := state.Group
+		svc := r.Adapter.ExternalService(group)
+		if svc == nil {
+			deleted, err := deleteOwnedService(ctx, r.Client, group, GroupExternalServiceName(group))
+			if err != nil {
+				return task.Fail().With("can't delete external service: %w", err)
+			}
+			if deleted {
+				r.Log.Info("External service deleted", "name", GroupExternalServiceName(group))
+			}
+			return task.Complete().With("external service is not specified")
+		}
+		if err := controllerutil.SetControllerReference(group, svc, r.Scheme); err != nil {
+			return task.Fail().With("can't set owner reference of external service: %w", err)
+		}
+		applied, err := Apply(ctx, r.Client, r.Recorder, group, svc)
+		if err != nil {
+			return task.Fail().With("can't apply external service: %w", err)
+		}
+		if applied {
+			r.Log.Info("External service applied", "name", svc.Name)
+		}
+		return task.Complete().With("external service is synced")
+	})
+}
+
+// TaskContextExternalService gets the external service of the instance
+func (r *InstanceReconciler[G, I]) TaskContextExternalService(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextExternalService", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		svc := &corev1.Service{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: instance.GetNamespace(), Name: InstanceExternalServiceName(instance)}, svc); err != nil {
+			if !errors.IsNotFound(err) {
+				return task.Fail().With("can't get external service: %w", err)
+			}
+			return task.Complete().With("external service is not found")
+		}
+		if metav1.IsControlledBy(svc, instance) {
+			state.ExternalService = svc
+		}
+		return task.Complete().With("external service is found")
+	})
+}
+
+// TaskExternalService applies the external service of the instance, it's deleted if not specified.
+// The pod waits for the external address to be allocated because it's advertised.
+func (r *InstanceReconciler[G, I]) TaskExternalService(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ExternalService", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		svc := r.Adapter.ExternalService(instance)
+		if svc == nil {
+			state.ExternalService = nil
+			deleted, err := deleteOwnedService(ctx, r.Client, instance, InstanceExternalServiceName(instance))
+			if err != nil {
+				return task.Fail().With("can't delete external service: %w", err)
+			}
+			if deleted {
+				r.Log.Info("External service deleted", "name", InstanceExternalServiceName(instance))
+			}
+			return task.Complete().With("external service is not specified")
+		}
+		if err := controllerutil.SetControllerReference(instance, svc, r.Scheme); err != nil {
+			return task.Fail().With("can't set owner reference of external service: %w", err)
+		}
+		applied, err := Apply(ctx, r.Client, r.Recorder, instance, svc)
+		if err != nil {
+			return task.Fail().With("can't apply external service: %w", err)
+		}
+		if applied {
+			r.Log.Info("External service applied", "name", svc.Name)
+		}
+		state.ExternalService = svc
+		if ExternalAddress(svc) == "" {
+			return task.Wait().With("waiting for the external address of service %s", svc.Name)
+		}
+		return task.Complete().With("external service is synced")
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestExternalAddress(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ports := []corev1.ServicePort{{Port: 20160, NodePort: 30160}}
+	tcs := []struct {
+		caseName string
+		svc      *corev1.Service
+		want     string
+	}{{
+		caseName: "no service",
+	}, {
+		caseName: "node port",
+		svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: ports,
+		}},
+		want: "${HOST_IP}:30160",
+	}, {
+		caseName: "node port is not allocated",
+		svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{{Port: 20160}},
+		}},
+	}, {
+		caseName: "load balancer is being provisioned",
+		svc: &corev1.Service{Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeLoadBalancer,
+			Ports: ports,
+		}},
+	}, {
+		caseName: "load balancer ip",
+		svc: &corev1.Service{
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}},
+			}},
+		},
+		want: "10.0.0.1:20160",
+	}, {
+		caseName: "load balancer ipv6",
+		svc: &corev1.Service{
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{IP: "fd00::1"}},
+			}},
+		},
+		want: "[fd00::1]:20160",
+	}, {
+		caseName: "load balancer hostname",
+		svc: &corev1.Service{
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, Ports: ports},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}},
+			}},
+		},
+		want: "lb.example.com:20160",
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(ExternalAddress(tc.svc)).To(Equal(tc.want), tc.caseName)
+	}
+}
@@ -0,0 +1,554 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/task"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/metrics"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+// GroupState is the state shared by tasks of a group reconciliation
+type GroupState[G Group, I Instance] struct {
+	Key types.NamespacedName
+
+	Group G
+	// GroupFound is false if the group is not found
+	GroupFound bool
+
+	Cluster *v1alpha1.Cluster
+
+	// Instances are all instances of the group, including the deleting ones
+	Instances []I
+
+	// UpdateRevision is the hash of the instance template of the group
+	UpdateRevision string
+
+	// RequeueAfter requeues the group if it's waiting for changes which are not watched
+	RequeueAfter time.Duration
+}
+
+// InstancePrefix returns the name prefix of instances of the group
+func (s *GroupState[G, I]) InstancePrefix() string {
+	return fmt.Sprintf("%s-%s-", s.Group.GetName(), s.Group.Component())
+}
+
+// GroupReconciler is a generic controller of component groups.
+// It scales instances of a group and rolls out changes of the instance template.
+type GroupReconciler[G Group, I Instance] struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// PDControl provides clients of PD to get the replication config of the cluster
+	PDControl pdapi.PDControlInterface
+	Adapter   GroupAdapter[G, I]
+}
+
+// SetupGroupController sets up a generic group controller with the Manager.
+func SetupGroupController[G Group, I Instance](mgr manager.Manager, name string, pdc pdapi.PDControlInterface, adapter GroupAdapter[G, I]) error {
+	r := &GroupReconciler[G, I]{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Log:       mgr.GetLogger().WithName(name),
+		Recorder:  mgr.GetEventRecorderFor(name),
+		PDControl: pdc,
+		Adapter:   adapter,
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(adapter.NewGroup()).
+		Owns(adapter.NewInstance()).
+		Owns(&corev1.Service{}).
+		Watches(&v1alpha1.Cluster{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForCluster)).
+		Watches(&policyv1.PodDisruptionBudget{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForPDB)).
+		WithOptions(controller.Options{}).
+		Complete(r)
+}
+
+func (r *GroupReconciler[G, I]) enqueueForCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.enqueueGroups(ctx, obj.GetNamespace(), obj.GetName())
+}
+
+// enqueueForPDB enqueues all groups of the component in the cluster, the budget is shared by them
+func (r *GroupReconciler[G, I]) enqueueForPDB(ctx context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels[v1alpha1.LabelKeyManagedBy] != v1alpha1.LabelValManagedByOperator ||
+		labels[v1alpha1.LabelKeyComponent] != r.Adapter.NewGroup().Component() {
+		return []reconcile.Request{}
+	}
+	return r.enqueueGroups(ctx, obj.GetNamespace(), labels[v1alpha1.LabelKeyCluster])
+}
+
+func (r *GroupReconciler[G, I]) enqueueGroups(ctx context.Context, ns, cluster string) []reconcile.Request {
+	groups, err := r.Adapter.ListGroups(ctx, r.Client, ns, cluster)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, group := range groups {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      group.GetName(),
+				Namespace: group.GetNamespace(),
+			},
+		})
+	}
+	return requests
+}
+
+// Reconcile reconciles a group by managing its instances
+func (r *GroupReconciler[G, I]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("group", req.NamespacedName)
+	state := &GroupState[G, I]{Key: req.NamespacedName}
+
+	runner := task.NewTaskRunner(log,
+		r.TaskContextGroup(state),
+		task.IfBreak(task.CondFunc(func() bool {
+			return !state.GroupFound || !state.Group.GetDeletionTimestamp().IsZero()
+		})),
+		r.TaskContextCluster(state),
+		r.TaskContextInstances(state),
+		// Instances are neither created nor deleted while the cluster is paused or the group is suspended
+		task.IfBreak(task.CondFunc(func() bool {
+			return state.Cluster.Spec.Paused || state.Group.IsSuspended()
+		}),
+			r.TaskStatus(state),
+		),
+		r.TaskReplace(state),
+		r.TaskScale(state),
+		r.TaskPDB(state),
+		r.TaskExternalService(state),
+		r.TaskUpdate(state),
+		r.TaskStatus(state),
+	)
+
+	res, err := runner.Run(ctx)
+	if err != nil && state.GroupFound {
+		r.Recorder.Event(state.Group, corev1.EventTypeWarning, v1alpha1.ReasonReconcileFailed, err.Error())
+	}
+	return res, err
+}
+
+// TaskContextGroup gets the group
+func (r *GroupReconciler[G, I]) TaskContextGroup(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextGroup", func(ctx context.Context) task.Result {
+		group := r.Adapter.NewGroup()
+		if err := r.Get(ctx, state.Key, group); err != nil {
+			if !errors.IsNotFound(err) {
+				return task.Fail().With("can't get group: %w", err)
+			}
+			metrics.DeleteGroup(state.Key.Namespace, group.Component(), state.Key.Name)
+			return task.Complete().With("group is not found")
+		}
+		state.Group = group
+		state.GroupFound = true
+		state.UpdateRevision = Hash(r.Adapter.Template(group))
+		return task.Complete().With("group is found")
+	})
+}
+
+// TaskContextCluster gets the cluster of the group
+func (r *GroupReconciler[G, I]) TaskContextCluster(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextCluster", func(ctx context.Context) task.Result {
+		cluster := &v1alpha1.Cluster{}
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: state.Key.Namespace,
+			Name:      state.Group.ClusterName(),
+		}, cluster); err != nil {
+			return task.Fail().With("can't get cluster: %w", err)
+		}
+		state.Cluster = cluster
+		return task.Complete().With("cluster is found")
+	})
+}
+
+// TaskContextInstances lists all instances of the group
+func (r *GroupReconciler[G, I]) TaskContextInstances(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextInstances", func(ctx context.Context) task.Result {
+		instances, err := r.Adapter.ListInstances(ctx, r.Client, state.Key.Namespace, client.MatchingLabels{
+			v1alpha1.LabelKeyCluster:   state.Group.ClusterName(),
+			v1alpha1.LabelKeyGroup:     state.Group.GetName(),
+			v1alpha1.LabelKeyComponent: state.Group.Component(),
+		})
+		if err != nil {
+			return task.Fail().With("can't list instances: %w", err)
+		}
+		state.Instances = instances
+		return task.Complete().With("%d instances are found", len(instances))
+	})
+}
+
+// TaskScale creates or deletes instances to match the desired replicas.
+// New instances fill ordinal gaps first, except names of scaled-in instances whose PVCs are retained
+// and names listed in scaleInInstances of the group. Instances listed in scaleInInstances or marked with
+// AnnoKeyScaleInFirst are deleted first on scale in.
+// Instances are prepared by PreDelete on scale in, and only deleted once they're deletable.
+func (r *GroupReconciler[G, I]) TaskScale(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("Scale", func(ctx context.Context) task.Result {
+		group := state.Group
+		desiredReplicas := group.GetReplicas()
+
+		// Instances being deleted, replaced or prepared for deletion still hold their names but are not counted as replicas
+		names := make([]string, 0, len(state.Instances))
+		instances := make([]I, 0, len(state.Instances))
+		preDeleted := []I{}
+		for _, instance := range state.Instances {
+			names = append(names, instance.GetName())
+			if !instance.GetDeletionTimestamp().IsZero() || IsReplacing(instance) {
+				continue
+			}
+			if r.Adapter.IsPreDeleted(instance) {
+				preDeleted = append(preDeleted, instance)
+				continue
+			}
+			instances = append(instances, instance)
+		}
+		currentReplicas := int32(len(instances))
+		prefix := state.InstancePrefix()
+
+		// Instances prepared by earlier scale in are deleted once they're deletable
+		pending, err := r.deleteIfDeletable(ctx, group, preDeleted)
+		if err != nil {
+			return task.Fail().With("%w", err)
+		}
+
+		// The condition is set again below if scale in is still limited
+		conds := &group.GetCommonStatus().Conditions
+		limited := meta.IsStatusConditionTrue(*conds, v1alpha1.CondScaleInLimited)
+		SetGroupScaleInLimitedCondition(conds, group.GetGeneration(), "")
+
+		// Scale out: create new instances with the update revision
+		if desiredReplicas > currentReplicas {
+			scaledIn, err := ScaledInPVCInstances(ctx, r.Client, group)
+			if err != nil {
+				return task.Fail().With("can't list retained pvcs: %w", err)
+			}
+			// Names listed to be scaled in are not reused
+			used := append(append(names, scaledIn...), group.GetScaleInInstances()...)
+			for _, name := range NextInstanceNames(prefix, used, int(desiredReplicas-currentReplicas)) {
+				instance, err := r.newInstance(state, name)
+				if err != nil {
+					return task.Fail().With("can't build instance %s: %w", name, err)
+				}
+				if _, err := Apply(ctx, r.Client, r.Recorder, group, instance); err != nil {
+					return task.Fail().With("can't create instance %s: %w", name, err)
+				}
+				r.Log.Info("created instance", "name", name)
+				r.Recorder.Eventf(group, corev1.EventTypeNormal, v1alpha1.ReasonInstanceCreated, "instance %s is created", name)
+				state.Instances = append(state.Instances, instance)
+			}
+			return task.Complete().With("scaled out to %d replicas", desiredReplicas)
+		}
+
+		// Scale in: delete marked instances first, then the highest ordinals.
+		// The replicas are capped by the min replicas of the group, e.g. the replication factor of PD.
+		if desiredReplicas < currentReplicas {
+			pdc := PDClient(r.PDControl, state.Cluster)
+			minReplicas, msg, err := r.Adapter.MinReplicas(ctx, r.Client, pdc, group)
+			if err != nil {
+				return task.Fail().With("can't get min replicas: %w", err)
+			}
+			if minReplicas > desiredReplicas {
+				if !limited {
+					r.Recorder.Eventf(group, corev1.EventTypeWarning, v1alpha1.ReasonBelowMinReplicas,
+						"can't scale in to %d replicas: %s", desiredReplicas, msg)
+				}
+				SetGroupScaleInLimitedCondition(conds, group.GetGeneration(), msg)
+				desiredReplicas = minReplicas
+			}
+			if desiredReplicas >= currentReplicas {
+				return task.Complete().With("scale in is limited: %s", msg)
+			}
+
+			SortForScaleIn(prefix, group.GetScaleInInstances(), instances)
+			scaledIn := instances[:currentReplicas-desiredReplicas]
+			for _, instance := range scaledIn {
+				if err := r.Adapter.PreDelete(ctx, r.Client, r.Recorder, group, instance); err != nil {
+					return task.Fail().With("can't prepare deletion of instance %s: %w", instance.GetName(), err)
+				}
+			}
+			n, err := r.deleteIfDeletable(ctx, group, scaledIn)
+			if err != nil {
+				return task.Fail().With("%w", err)
+			}
+			pending += n
+			return task.Complete().With("scaled in to %d replicas, %d instances wait to be deletable", desiredReplicas, pending)
+		}
+
+		if pending > 0 {
+			return task.Complete().With("%d instances wait to be deletable", pending)
+		}
+		return task.Complete().With("replicas are up to date")
+	})
+}
+
+// deleteIfDeletable deletes instances prepared by PreDelete once they're deletable.
+// Deleted instances are marked with AnnoKeyScaledIn, so their retained PVCs are not re-adopted.
+// It returns how many instances are not deletable yet, the group is reconciled again when their status is changed.
+func (r *GroupReconciler[G, I]) deleteIfDeletable(ctx context.Context, group G, instances []I) (int, error) {
+	pending := 0
+	for _, instance := range instances {
+		if !r.Adapter.IsDeletable(instance) {
+			pending++
+			continue
+		}
+		if !IsScaledIn(instance) {
+			patch := client.MergeFrom(instance.DeepCopyObject().(client.Object))
+			annotations := instance.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[v1alpha1.AnnoKeyScaledIn] = "true"
+			instance.SetAnnotations(annotations)
+			if err := r.Patch(ctx, instance, patch); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return pending, fmt.Errorf("can't mark instance %s as scaled in: %w", instance.GetName(), err)
+			}
+		}
+		if err := r.Delete(ctx, instance); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return pending, fmt.Errorf("can't delete instance %s: %w", instance.GetName(), err)
+		}
+		r.Log.Info("deleted instance", "name", instance.GetName())
+		r.Recorder.Eventf(group, corev1.EventTypeNormal, v1alpha1.ReasonInstanceDeleted, "instance %s is deleted", instance.GetName())
+	}
+	return pending, nil
+}
+
+// TaskUpdate rolls out changes to instances one by one.
+// An outdated instance is updated to the instance template, and an outdated pod,
+// e.g. changed by the cluster, is recreated after its instance is marked with AnnoKeyRestartPod.
+// An instance is only updated or restarted when all other instances of the component in the cluster
+// are available and no other pod is being restarted.
+// Instances which have to be replaced are left to TaskReplace, and instances prepared for deletion are skipped.
+func (r *GroupReconciler[G, I]) TaskUpdate(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("Update", func(ctx context.Context) task.Result {
+		// Marks of restarted pods are removed, so later changes wait for the group again
+		for _, instance := range state.Instances {
+			if IsPodRestartAllowed(instance) && IsPodSynced(instance) {
+				if err := r.markPodRestart(ctx, instance, false); err != nil {
+					return task.Fail().With("can't unmark restart of instance %s: %w", instance.GetName(), err)
+				}
+			}
+		}
+
+		// The status is still updated by TaskStatus, which also requeues the group until MinReadySeconds elapses.
+		// Instances of other groups are not watched, they're checked again later.
+		all, err := r.Adapter.ListInstances(ctx, r.Client, state.Key.Namespace, client.MatchingLabels{
+			v1alpha1.LabelKeyCluster:   state.Group.ClusterName(),
+			v1alpha1.LabelKeyComponent: state.Group.Component(),
+		})
+		if err != nil {
+			return task.Fail().With("can't list instances of the cluster: %w", err)
+		}
+		wait := func(instance I, format string) task.Result {
+			if instance.GetLabels()[v1alpha1.LabelKeyGroup] != state.Group.GetName() {
+				state.RequeueAfter = statusResyncInterval
+			}
+			return task.Complete().With(format, instance.GetName())
+		}
+		now := time.Now()
+		for _, instance := range all {
+			if !instance.GetDeletionTimestamp().IsZero() || IsReplacing(instance) || r.Adapter.IsPreDeleted(instance) {
+				continue
+			}
+			if IsPodRestartAllowed(instance) && !IsPodSynced(instance) {
+				return wait(instance, "wait for pod of instance %s to be restarted")
+			}
+			available, remaining := IsInstanceAvailable(instance.GetCommonStatus().Conditions, r.Adapter.MinReadySeconds(), now)
+			if remaining > 0 {
+				return wait(instance, "wait for instance %s to be available")
+			}
+			if !available {
+				return wait(instance, "wait for instance %s to be ready")
+			}
+		}
+
+		outdated := []I{}
+		restarts := []I{}
+		for _, instance := range state.Instances {
+			if !instance.GetDeletionTimestamp().IsZero() || IsReplacing(instance) || r.Adapter.IsPreDeleted(instance) {
+				continue
+			}
+			switch {
+			case instance.GetLabels()[v1alpha1.LabelKeyInstanceRevisionHash] != state.UpdateRevision:
+				if r.replaceReason(state, instance) == "" {
+					outdated = append(outdated, instance)
+				}
+			case IsPodOutdated(instance):
+				restarts = append(restarts, instance)
+			}
+		}
+
+		// The pod of an updated instance is outdated, it's restarted in a later round
+		if len(outdated) > 0 {
+			SortForScaleIn(state.InstancePrefix(), state.Group.GetScaleInInstances(), outdated)
+			instance, err := r.newInstance(state, outdated[0].GetName())
+			if err != nil {
+				return task.Fail().With("can't build instance %s: %w", outdated[0].GetName(), err)
+			}
+			if _, err := Apply(ctx, r.Client, r.Recorder, state.Group, instance); err != nil {
+				return task.Fail().With("can't update instance %s: %w", instance.GetName(), err)
+			}
+			r.Log.Info("updated instance", "name", instance.GetName(), "revision", state.UpdateRevision)
+			r.Recorder.Eventf(state.Group, corev1.EventTypeNormal, v1alpha1.ReasonInstanceUpdated,
+				"instance %s is updated to revision %s", instance.GetName(), state.UpdateRevision)
+			return task.Complete().With("instance %s is updated", instance.GetName())
+		}
+
+		if len(restarts) > 0 {
+			SortForScaleIn(state.InstancePrefix(), state.Group.GetScaleInInstances(), restarts)
+			instance := restarts[0]
+			if err := r.markPodRestart(ctx, instance, true); err != nil {
+				return task.Fail().With("can't mark restart of instance %s: %w", instance.GetName(), err)
+			}
+			r.Log.Info("restarting pod", "name", instance.GetName())
+			r.Recorder.Eventf(state.Group, corev1.EventTypeNormal, v1alpha1.ReasonPodRestarted,
+				"outdated pod of instance %s is being restarted", instance.GetName())
+			return task.Complete().With("pod of instance %s is being restarted", instance.GetName())
+		}
+		return task.Complete().With("all instances are up to date")
+	})
+}
+
+// markPodRestart sets or removes AnnoKeyRestartPod of an instance
+func (r *GroupReconciler[G, I]) markPodRestart(ctx context.Context, instance I, restart bool) error {
+	patch := client.MergeFrom(instance.DeepCopyObject().(client.Object))
+	annotations := instance.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if restart {
+		annotations[v1alpha1.AnnoKeyRestartPod] = "true"
+	} else {
+		delete(annotations, v1alpha1.AnnoKeyRestartPod)
+	}
+	instance.SetAnnotations(annotations)
+	return r.Patch(ctx, instance, patch)
+}
+
+// TaskStatus aggregates the status of the group from its instances.
+// Ready replicas are the instances which have been ready for at least MinReadySeconds.
+func (r *GroupReconciler[G, I]) TaskStatus(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("Status", func(ctx context.Context) task.Result {
+		group := state.Group
+		generation := group.GetGeneration()
+		commonStatus := group.GetCommonStatus()
+		groupStatus := group.GetGroupStatus()
+
+		var replicas, ready, updated int32
+		requeueAfter := state.RequeueAfter
+		now := time.Now()
+		synced := true
+		instanceConds := make([][]metav1.Condition, 0, len(state.Instances))
+		for _, instance := range state.Instances {
+			conds := instance.GetCommonStatus().Conditions
+			instanceConds = append(instanceConds, conds)
+			replicas++
+			available, remaining := IsInstanceAvailable(conds, r.Adapter.MinReadySeconds(), now)
+			if available {
+				ready++
+			}
+			if remaining > 0 && (requeueAfter == 0 || remaining < requeueAfter) {
+				requeueAfter = remaining
+			}
+			if instance.GetLabels()[v1alpha1.LabelKeyInstanceRevisionHash] == state.UpdateRevision {
+				updated++
+			}
+			if !instance.GetDeletionTimestamp().IsZero() || !meta.IsStatusConditionTrue(conds, v1alpha1.CondSynced) {
+				synced = false
+			}
+		}
+		if replicas != group.GetReplicas() || updated != replicas {
+			synced = false
+		}
+
+		commonStatus.ObservedGeneration = generation
+		commonStatus.UpdateRevision = state.UpdateRevision
+		if updated == replicas {
+			commonStatus.CurrentRevision = state.UpdateRevision
+		}
+		var current int32
+		for _, instance := range state.Instances {
+			if instance.GetLabels()[v1alpha1.LabelKeyInstanceRevisionHash] == commonStatus.CurrentRevision {
+				current++
+			}
+		}
+
+		groupStatus.Replicas = replicas
+		groupStatus.ReadyReplicas = ready
+		groupStatus.UpdatedReplicas = updated
+		groupStatus.CurrentReplicas = current
+		groupStatus.Selector = fmt.Sprintf("%s=%s,%s=%s",
+			v1alpha1.LabelKeyCluster, group.ClusterName(),
+			v1alpha1.LabelKeyGroup, group.GetName())
+
+		metrics.SetGroupReplicas(group.GetNamespace(), group.ClusterName(), group.Component(), group.GetName(),
+			group.GetReplicas(), current, updated, ready)
+
+		SetGroupReadyCondition(&commonStatus.Conditions, generation, replicas, ready)
+		SetGroupSyncedCondition(&commonStatus.Conditions, generation, synced)
+		SetGroupSuspendedCondition(&commonStatus.Conditions, generation, group.IsSuspended(), instanceConds)
+
+		if err := r.Status().Update(ctx, group); err != nil {
+			return task.Fail().With("can't update status: %w", err)
+		}
+		if requeueAfter > 0 {
+			return task.Retry(requeueAfter).With("status is updated, wait for instances to be available")
+		}
+		return task.Complete().With("status is updated")
+	})
+}
+
+// newInstance builds the desired instance of the group with the update revision
+func (r *GroupReconciler[G, I]) newInstance(state *GroupState[G, I], name string) (I, error) {
+	group := state.Group
+	instance := r.Adapter.NewInstanceFromGroup(group, name)
+	labels := InstanceLabels(group.ClusterName(), group.Component(), group.GetName(), name)
+	labels[v1alpha1.LabelKeyInstanceRevisionHash] = state.UpdateRevision
+	instance.SetLabels(labels)
+	if err := controllerutil.SetControllerReference(group, instance, r.Scheme); err != nil {
+		return instance, err
+	}
+	return instance, nil
+}
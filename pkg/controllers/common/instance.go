@@ -0,0 +1,760 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/task"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/metrics"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+const (
+	// statusResyncInterval is the interval to resync the status of an instance from PD
+	statusResyncInterval = 30 * time.Second
+	// resizeCheckInterval is the interval to check progress of resizing volumes
+	resizeCheckInterval = 15 * time.Second
+)
+
+// InstanceState is the state shared by tasks of an instance reconciliation
+type InstanceState[G Group, I Instance] struct {
+	Key types.NamespacedName
+
+	Instance I
+	// InstanceFound is false if the instance is not found
+	InstanceFound bool
+
+	Cluster *v1alpha1.Cluster
+
+	Group G
+	// GroupFound is false if the group of the instance is not found
+	GroupFound bool
+
+	// Pod is nil if it's not created
+	Pod *corev1.Pod
+
+	// ExternalService is nil if the instance has no external service
+	ExternalService *corev1.Service
+
+	// ConfigHash is the hash of the rendered config and start script, it's set after the ConfigMap is synced
+	ConfigHash string
+
+	// VolumesResizedCondition is the resize progress of PVCs, it's nil if PVCs are not synced
+	VolumesResizedCondition *metav1.Condition
+
+	// RequeueAfter is set if the instance should be reconciled again, e.g. to check progress of resizing
+	RequeueAfter time.Duration
+}
+
+// PodContext returns the context to build the pod of the instance
+func (s *InstanceState[G, I]) PodContext() *PodContext {
+	return &PodContext{
+		Cluster:         s.Cluster,
+		ExternalAddress: ExternalAddress(s.ExternalService),
+		ConfigHash:      s.ConfigHash,
+	}
+}
+
+// IsSuspended returns whether the group of the instance is suspended
+func (s *InstanceState[G, I]) IsSuspended() bool {
+	return s.GroupFound && s.Group.IsSuspended()
+}
+
+// InstanceReconciler is a generic controller of component instances.
+// It manages the Pod, ConfigMap, PVCs and the shared headless Service of an instance.
+type InstanceReconciler[G Group, I Instance] struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// PDControl provides clients of PD to get the status of instances in PD's view
+	PDControl pdapi.PDControlInterface
+	Adapter   InstanceAdapter[G, I]
+}
+
+// SetupInstanceController sets up a generic instance controller with the Manager.
+func SetupInstanceController[G Group, I Instance](mgr manager.Manager, name string, pdc pdapi.PDControlInterface, adapter InstanceAdapter[G, I]) error {
+	r := &InstanceReconciler[G, I]{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Log:       mgr.GetLogger().WithName(name),
+		Recorder:  mgr.GetEventRecorderFor(name),
+		PDControl: pdc,
+		Adapter:   adapter,
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		For(adapter.NewInstance()).
+		Owns(&corev1.Pod{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.Service{}).
+		Watches(&v1alpha1.Cluster{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForCluster)).
+		Watches(adapter.NewGroup(), handler.EnqueueRequestsFromMapFunc(r.enqueueForGroup)).
+		WithOptions(controller.Options{}).
+		Complete(r)
+}
+
+func (r *InstanceReconciler[G, I]) enqueueForCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.enqueueByLabels(ctx, obj.GetNamespace(), client.MatchingLabels{
+		v1alpha1.LabelKeyCluster:   obj.GetName(),
+		v1alpha1.LabelKeyComponent: r.Adapter.NewInstance().Component(),
+	})
+}
+
+func (r *InstanceReconciler[G, I]) enqueueForGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	group := obj.(G)
+	return r.enqueueByLabels(ctx, group.GetNamespace(), client.MatchingLabels{
+		v1alpha1.LabelKeyCluster:   group.ClusterName(),
+		v1alpha1.LabelKeyGroup:     group.GetName(),
+		v1alpha1.LabelKeyComponent: group.Component(),
+	})
+}
+
+func (r *InstanceReconciler[G, I]) enqueueByLabels(ctx context.Context, ns string, labels client.MatchingLabels) []reconcile.Request {
+	instances, err := r.Adapter.ListInstances(ctx, r.Client, ns, labels)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, instance := range instances {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      instance.GetName(),
+				Namespace: instance.GetNamespace(),
+			},
+		})
+	}
+	return requests
+}
+
+// Reconcile manages Pod, ConfigMap, and PVCs for an instance
+func (r *InstanceReconciler[G, I]) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("instance", req.NamespacedName)
+	state := &InstanceState[G, I]{Key: req.NamespacedName}
+
+	runner := task.NewTaskRunner(log,
+		r.TaskContextInstance(state),
+		task.IfBreak(task.CondFunc(func() bool { return !state.InstanceFound })),
+		// Retain PVCs before the instance is deleted
+		task.IfBreak(task.CondFunc(func() bool { return !state.Instance.GetDeletionTimestamp().IsZero() }),
+			r.TaskFinalize(state),
+		),
+		r.TaskContextCluster(state),
+		r.TaskContextGroup(state),
+		r.TaskContextPod(state),
+		r.TaskContextExternalService(state),
+		r.TaskFinalizer(state),
+		// Managed resources are frozen while the cluster is paused, only status is updated
+		task.IfBreak(task.CondFunc(func() bool { return state.Cluster.Spec.Paused }),
+			r.TaskStatus(state),
+		),
+		// Stop the Pod but keep PVCs, ConfigMap and the instance itself
+		task.IfBreak(task.CondFunc(state.IsSuspended),
+			r.TaskSuspendPod(state),
+			r.TaskStatus(state),
+		),
+		r.TaskValidate(state),
+		r.TaskService(state),
+		r.TaskExternalService(state),
+		r.TaskConfigMap(state),
+		r.TaskPVC(state),
+		r.TaskPod(state),
+		r.TaskSyncPD(state),
+		r.TaskStatus(state),
+	)
+
+	res, err := runner.Run(ctx)
+	if err != nil && state.InstanceFound {
+		r.Recorder.Event(state.Instance, corev1.EventTypeWarning, v1alpha1.ReasonReconcileFailed, err.Error())
+	}
+	return res, err
+}
+
+// TaskContextInstance gets the instance
+func (r *InstanceReconciler[G, I]) TaskContextInstance(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextInstance", func(ctx context.Context) task.Result {
+		instance := r.Adapter.NewInstance()
+		if err := r.Get(ctx, state.Key, instance); err != nil {
+			if !errors.IsNotFound(err) {
+				return task.Fail().With("can't get instance: %w", err)
+			}
+			metrics.DeleteInstance(state.Key.Namespace, state.Key.Name)
+			return task.Complete().With("instance is not found")
+		}
+		state.Instance = instance
+		state.InstanceFound = true
+		return task.Complete().With("instance is found")
+	})
+}
+
+// TaskContextCluster gets the cluster of the instance
+func (r *InstanceReconciler[G, I]) TaskContextCluster(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextCluster", func(ctx context.Context) task.Result {
+		cluster := &v1alpha1.Cluster{}
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: state.Key.Namespace,
+			Name:      state.Instance.ClusterName(),
+		}, cluster); err != nil {
+			return task.Fail().With("can't get cluster: %w", err)
+		}
+		state.Cluster = cluster
+		return task.Complete().With("cluster is found")
+	})
+}
+
+// TaskContextGroup gets the group of the instance
+func (r *InstanceReconciler[G, I]) TaskContextGroup(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextGroup", func(ctx context.Context) task.Result {
+		group := r.Adapter.NewGroup()
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: state.Key.Namespace,
+			Name:      state.Instance.GetLabels()[v1alpha1.LabelKeyGroup],
+		}, group); err != nil {
+			if !errors.IsNotFound(err) {
+				return task.Fail().With("can't get group: %w", err)
+			}
+			return task.Complete().With("group is not found")
+		}
+		state.Group = group
+		state.GroupFound = true
+		return task.Complete().With("group is found")
+	})
+}
+
+// TaskContextPod gets the pod of the instance
+func (r *InstanceReconciler[G, I]) TaskContextPod(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ContextPod", func(ctx context.Context) task.Result {
+		pod := &corev1.Pod{}
+		if err := r.Get(ctx, state.Key, pod); err != nil {
+			if !errors.IsNotFound(err) {
+				return task.Fail().With("can't get pod: %w", err)
+			}
+			return task.Complete().With("pod is not found")
+		}
+		state.Pod = pod
+		return task.Complete().With("pod is found")
+	})
+}
+
+// TaskSuspendPod deletes the pod with the default grace period once the instance is stoppable,
+// e.g. leaders of the TiKV store are evicted. The instance is reconciled again when its status changes.
+func (r *InstanceReconciler[G, I]) TaskSuspendPod(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("SuspendPod", func(ctx context.Context) task.Result {
+		if state.Pod == nil {
+			return task.Complete().With("pod is deleted")
+		}
+		if !state.Pod.DeletionTimestamp.IsZero() {
+			return task.Complete().With("pod is deleting")
+		}
+		if ok, msg := r.Adapter.IsStoppable(state.Instance); !ok {
+			return task.Complete().With("pod is kept: %s", msg)
+		}
+		if err := r.Delete(ctx, state.Pod); err != nil && !errors.IsNotFound(err) {
+			return task.Fail().With("can't delete pod: %w", err)
+		}
+		r.Log.Info("Pod deleted", "name", state.Pod.Name)
+		r.Recorder.Event(state.Instance, corev1.EventTypeNormal, v1alpha1.ReasonSuspended, "pod is deleted because the group is suspended")
+		return task.Complete().With("pod is deleted")
+	})
+}
+
+// TaskValidate validates the instance before its resources are managed
+func (r *InstanceReconciler[G, I]) TaskValidate(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("Validate", func(ctx context.Context) task.Result {
+		if err := validateVolumeMounts(state.Instance.GetVolumes(), r.Adapter.DefaultMountPath); err != nil {
+			return task.Fail().With("invalid volumes: %w", err)
+		}
+		return task.Complete().With("instance is valid")
+	})
+}
+
+// TaskService ensures the headless service shared by all instances of the component
+func (r *InstanceReconciler[G, I]) TaskService(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("Service", func(ctx context.Context) task.Result {
+		// Don't set owner reference for service (shared by all instances)
+		svc := r.Adapter.Service(state.Instance)
+		applied, err := Apply(ctx, r.Client, r.Recorder, state.Instance, svc)
+		if err != nil {
+			return task.Fail().With("can't apply service: %w", err)
+		}
+		if applied {
+			r.Log.Info("Service applied", "name", svc.Name)
+		}
+		return task.Complete().With("service is synced")
+	})
+}
+
+// TaskConfigMap ensures the ConfigMap of the instance
+func (r *InstanceReconciler[G, I]) TaskConfigMap(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("ConfigMap", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		config, err := r.Adapter.Config(instance)
+		if err != nil {
+			return task.Fail().With("can't render config: %w", err)
+		}
+		script := r.Adapter.StartScript(state.PodContext(), instance)
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapName(instance),
+				Namespace: instance.GetNamespace(),
+				Labels:    labelsOf(instance),
+			},
+			Data: map[string]string{
+				v1alpha1.ConfigMapKeyConfig:      config,
+				v1alpha1.ConfigMapKeyStartScript: script.Render(),
+			},
+		}
+		if err := controllerutil.SetControllerReference(instance, cm, r.Scheme); err != nil {
+			return task.Fail().With("can't set owner of configmap: %w", err)
+		}
+
+		applied, err := Apply(ctx, r.Client, r.Recorder, instance, cm)
+		if err != nil {
+			return task.Fail().With("can't apply configmap: %w", err)
+		}
+		if applied {
+			r.Log.Info("ConfigMap applied", "name", cm.Name)
+			if state.Pod != nil {
+				r.Recorder.Event(instance, corev1.EventTypeNormal, v1alpha1.ReasonConfigUpdated, "config is updated")
+			}
+		}
+		state.ConfigHash = Hash(cm.Data)
+		return task.Complete().With("configmap is synced")
+	})
+}
+
+// TaskPVC ensures the PVCs of the instance.
+// A PVC retained by a deleted instance with the same name is re-adopted.
+// Only the storage request of an existing PVC is changed, volumes are expanded in place
+// if the storage class allows it. Shrinking is not supported.
+// The pod is restarted if the file system of a volume can't be resized online.
+func (r *InstanceReconciler[G, I]) TaskPVC(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("PVC", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		resize := &volumeResize{}
+		for _, vol := range instance.GetVolumes() {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      PVCName(instance, vol.Name),
+					Namespace: instance.GetNamespace(),
+					Labels:    labelsOf(instance),
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage: vol.Storage,
+						},
+					},
+					StorageClassName: vol.StorageClassName,
+				},
+			}
+			pvc.Labels[v1alpha1.LabelKeyVolumeName] = vol.Name
+			pvc.Annotations = map[string]string{
+				v1alpha1.AnnoKeyPVCRetentionPolicy: string(state.PVCRetentionPolicy()),
+			}
+
+			current := &corev1.PersistentVolumeClaim{}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(pvc), current); err != nil {
+				if !errors.IsNotFound(err) {
+					return task.Fail().With("can't get pvc %s: %w", pvc.Name, err)
+				}
+			} else {
+				if current.Labels[v1alpha1.LabelKeyRetained] == "true" {
+					if err := r.readoptPVC(ctx, instance, vol.Name, current); err != nil {
+						return task.Fail().With("can't re-adopt pvc %s: %w", pvc.Name, err)
+					}
+				}
+				// The storage class is immutable, only the storage request may be changed
+				pvc.Spec.StorageClassName = current.Spec.StorageClassName
+				requested := current.Spec.Resources.Requests[corev1.ResourceStorage]
+				switch vol.Storage.Cmp(requested) {
+				case 1:
+					allowed, err := isExpansionAllowed(ctx, r.Client, current.Spec.StorageClassName)
+					if err != nil {
+						return task.Fail().With("can't get storage class of pvc %s: %w", pvc.Name, err)
+					}
+					if !allowed {
+						pvc.Spec.Resources.Requests[corev1.ResourceStorage] = requested
+						resize.add(v1alpha1.ReasonVolumeExpansionForbidden,
+							fmt.Sprintf("storage class of pvc %s doesn't allow volume expansion", pvc.Name))
+						break
+					}
+					r.Recorder.Eventf(instance, corev1.EventTypeNormal, v1alpha1.ReasonVolumeExpanding,
+						"pvc %s is expanded from %s to %s", pvc.Name, requested.String(), vol.Storage.String())
+				case -1:
+					pvc.Spec.Resources.Requests[corev1.ResourceStorage] = requested
+					resize.add(v1alpha1.ReasonVolumeShrinkForbidden,
+						fmt.Sprintf("pvc %s can't be shrunk from %s to %s", pvc.Name, requested.String(), vol.Storage.String()))
+				}
+				resize.observe(current, pvc.Spec.Resources.Requests[corev1.ResourceStorage])
+			}
+			if err := controllerutil.SetControllerReference(instance, pvc, r.Scheme); err != nil {
+				return task.Fail().With("can't set owner of pvc %s: %w", pvc.Name, err)
+			}
+
+			applied, err := Apply(ctx, r.Client, r.Recorder, instance, pvc)
+			if err != nil {
+				return task.Fail().With("can't apply pvc %s: %w", pvc.Name, err)
+			}
+			if applied {
+				r.Log.Info("PVC applied", "name", pvc.Name)
+			}
+		}
+
+		cond := resize.condition(instance.GetGeneration())
+		state.VolumesResizedCondition = &cond
+
+		if resize.needRestartPod(state.Pod, time.Now()) {
+			if err := r.Delete(ctx, state.Pod); err != nil && !errors.IsNotFound(err) {
+				return task.Fail().With("can't restart pod to resize file system: %w", err)
+			}
+			r.Log.Info("Pod restarted to resize file system", "name", state.Pod.Name)
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, v1alpha1.ReasonPodRestarted,
+				"pod %s is restarted to resize file system", state.Pod.Name)
+			return task.Wait().With("wait for the pod to be deleted")
+		}
+		if resize.reason == v1alpha1.ReasonVolumeResizing || resize.reason == v1alpha1.ReasonFileSystemResizePending {
+			// Progress of resizing is not watched if the PVC conditions don't change, check it later
+			state.RequeueAfter = resizeCheckInterval
+		}
+		return task.Complete().With("pvcs are synced")
+	})
+}
+
+// TaskPod ensures the Pod of the instance.
+// An outdated Pod, i.e. its spec hash or config hash is changed, is only recreated after
+// the group allows it by AnnoKeyRestartPod and the instance is stoppable,
+// so pods of a group are restarted one by one even if the change comes from the cluster.
+func (r *InstanceReconciler[G, I]) TaskPod(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("Pod", func(ctx context.Context) task.Result {
+		expected, err := r.newPod(state.PodContext(), state.Instance)
+		if err != nil {
+			return task.Fail().With("can't build pod: %w", err)
+		}
+		// Annotations are not part of the spec hash, changing them doesn't recreate the pod
+		scrape, err := r.scrapeByAnnotations(state.Cluster)
+		if err != nil {
+			return task.Fail().With("can't check monitor: %w", err)
+		}
+		if scrape {
+			expected.Annotations = ScrapeAnnotations(r.Adapter.MetricsPort(state.Instance))
+		}
+
+		pod := state.Pod
+		if pod != nil {
+			if !pod.DeletionTimestamp.IsZero() {
+				return task.Wait().With("pod is deleting")
+			}
+
+			if what := podChange(pod, expected); what != "" {
+				if !IsPodRestartAllowed(state.Instance) {
+					return task.Complete().With("pod is outdated, wait for the group to restart it")
+				}
+				if ok, msg := r.Adapter.IsStoppable(state.Instance); !ok {
+					return task.Complete().With("pod is kept: %s", msg)
+				}
+				if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+					return task.Fail().With("can't delete pod: %w", err)
+				}
+				r.Log.Info("Pod deleted for recreation", "name", pod.Name, "changed", what)
+				r.Recorder.Eventf(state.Instance, corev1.EventTypeNormal, v1alpha1.ReasonPodNotUpToDate,
+					"pod is deleted to be recreated with the new %s", what)
+				return task.Wait().With("pod is recreating")
+			}
+			// The spec of an existing pod is never changed, only drifted metadata is applied.
+			// Hashes missing on a pod created by an older operator are added instead of recreating it.
+			expected.Spec = *pod.Spec.DeepCopy()
+		}
+
+		applied, err := Apply(ctx, r.Client, r.Recorder, state.Instance, expected)
+		if err != nil {
+			return task.Fail().With("can't apply pod: %w", err)
+		}
+		if applied {
+			r.Log.Info("Pod applied", "name", expected.Name)
+			if pod == nil {
+				r.Recorder.Event(state.Instance, corev1.EventTypeNormal, v1alpha1.ReasonPodCreated, "pod is created")
+			}
+		}
+		state.Pod = expected
+		return task.Complete().With("pod is synced")
+	})
+}
+
+// TaskSyncPD makes the changes of the instance in PD, e.g. removes its store.
+// It's the only task of an instance which changes PD, so nothing is changed while the cluster is paused.
+func (r *InstanceReconciler[G, I]) TaskSyncPD(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("SyncPD", func(ctx context.Context) task.Result {
+		pdc := PDClient(r.PDControl, state.Cluster)
+		inProgress, err := r.Adapter.SyncPD(pdc, state.Instance)
+		if err != nil {
+			return task.Fail().With("can't sync instance to PD: %w", err)
+		}
+		if !inProgress {
+			return task.Complete().With("instance is synced to PD")
+		}
+		// Progress in PD can't be watched, check it later even if the pod is gone
+		if state.RequeueAfter == 0 || state.RequeueAfter > statusResyncInterval {
+			state.RequeueAfter = statusResyncInterval
+		}
+		return task.Complete().With("instance is being synced to PD")
+	})
+}
+
+// TaskStatus updates the status of the instance from its Pod and PD.
+// The instance is requeued periodically because changes in PD can't be watched.
+func (r *InstanceReconciler[G, I]) TaskStatus(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("Status", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		pod := state.Pod
+		status := instance.GetCommonStatus()
+		generation := instance.GetGeneration()
+
+		pdc := PDClient(r.PDControl, state.Cluster)
+		healthy, msg, pdErr := r.Adapter.UpdateStatus(pdc, r.Recorder, state.PodContext(), instance, pod)
+		if pdErr != nil {
+			healthy = false
+			msg = fmt.Sprintf("can't get status from PD: %v", pdErr)
+			r.Recorder.Event(instance, corev1.EventTypeWarning, v1alpha1.ReasonPDAPIError, msg)
+		}
+
+		status.ObservedGeneration = generation
+		status.CurrentRevision = instance.GetLabels()[v1alpha1.LabelKeyInstanceRevisionHash]
+		status.UpdateRevision = status.CurrentRevision
+		SetInstanceSuspendedCondition(&status.Conditions, generation, state.IsSuspended(), pod != nil)
+		SetInstanceRunningCondition(&status.Conditions, generation, pod)
+		SetInstanceReadyCondition(&status.Conditions, generation, pod, healthy, msg)
+
+		expected, err := r.newPod(state.PodContext(), instance)
+		if err != nil {
+			return task.Fail().With("can't build pod: %w", err)
+		}
+		SetInstanceSyncedCondition(&status.Conditions, generation, state.IsSuspended(), pod, podChange(pod, expected) != "")
+		if state.VolumesResizedCondition != nil {
+			meta.SetStatusCondition(&status.Conditions, *state.VolumesResizedCondition)
+		}
+
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return task.Fail().With("can't update status: %w", err)
+		}
+		requeueAfter := state.RequeueAfter
+		if pod != nil && (requeueAfter == 0 || requeueAfter > statusResyncInterval) {
+			requeueAfter = statusResyncInterval
+		}
+		if requeueAfter == 0 {
+			return task.Complete().With("status is updated")
+		}
+		return task.Retry(requeueAfter).With("status is updated, resync it later")
+	})
+}
+
+// scrapeByAnnotations returns whether metrics are advertised by pod annotations,
+// i.e. monitoring is enabled but the CRD of the monitor is not installed
+func (r *InstanceReconciler[G, I]) scrapeByAnnotations(cluster *v1alpha1.Cluster) (bool, error) {
+	if cluster.Spec.Monitoring == nil {
+		return false, nil
+	}
+	installed, err := IsMonitorInstalled(r.RESTMapper(), MonitorKind(cluster.Spec.Monitoring))
+	if err != nil {
+		return false, err
+	}
+	return !installed, nil
+}
+
+// newPod builds the expected Pod of an instance
+func (r *InstanceReconciler[G, I]) newPod(pc *PodContext, instance I) (*corev1.Pod, error) {
+	container := r.Adapter.Container(instance)
+	if pc.ExternalAddress != "" {
+		// The node ip is referenced by the external address of a NodePort service
+		container.Env = append(container.Env, corev1.EnvVar{Name: hostIPEnv, ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"},
+		}})
+	}
+	network := instance.GetNetwork()
+	if network.IsHost() {
+		// The pod ip is advertised with host network
+		container.Env = append(container.Env, corev1.EnvVar{Name: podIPEnv, ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+		}})
+	}
+
+	// Resources
+	resources := instance.GetResources()
+	container.Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	if resources.CPU != nil {
+		container.Resources.Requests[corev1.ResourceCPU] = *resources.CPU
+		container.Resources.Limits[corev1.ResourceCPU] = *resources.CPU
+	}
+	if resources.Memory != nil {
+		container.Resources.Requests[corev1.ResourceMemory] = *resources.Memory
+		container.Resources.Limits[corev1.ResourceMemory] = *resources.Memory
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: v1alpha1.VolumeNameConfig,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: ConfigMapName(instance),
+					},
+				},
+			},
+		},
+	}
+
+	// Volume mounts for data volumes
+	for _, vol := range instance.GetVolumes() {
+		for i := range vol.Mounts {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      vol.Name,
+				MountPath: MountPath(&vol.Mounts[i], r.Adapter.DefaultMountPath),
+				SubPath:   vol.Mounts[i].SubPath,
+			})
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name: vol.Name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: PVCName(instance, vol.Name),
+				},
+			},
+		})
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.GetName(),
+			Namespace: instance.GetNamespace(),
+			Labels:    labelsOf(instance),
+		},
+		Spec: corev1.PodSpec{
+			// The pod is resolvable as <hostname>.<subdomain> by the headless service
+			Hostname:      instance.GetName(),
+			Subdomain:     r.Adapter.Service(instance).Name,
+			Containers:    []corev1.Container{container},
+			Volumes:       volumes,
+			RestartPolicy: corev1.RestartPolicyAlways,
+		},
+	}
+	if network.IsHost() {
+		pod.Spec.HostNetwork = true
+		// Services of the cluster are still resolvable
+		pod.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+	}
+	pod.Labels[v1alpha1.LabelKeyPodSpecHash] = Hash(&pod.Spec)
+	if pc.ConfigHash != "" {
+		pod.Labels[v1alpha1.LabelKeyConfigHash] = pc.ConfigHash
+	}
+
+	if err := controllerutil.SetControllerReference(instance, pod, r.Scheme); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// podChange returns what makes a pod outdated, i.e. "spec" or "config", it's empty if the pod is up to date.
+// A hash missing on a pod created by an older operator is not a change, it's added to the pod.
+func podChange(pod, expected *corev1.Pod) string {
+	if pod == nil {
+		return ""
+	}
+	if hash := pod.Labels[v1alpha1.LabelKeyPodSpecHash]; hash != "" && hash != expected.Labels[v1alpha1.LabelKeyPodSpecHash] {
+		return "spec"
+	}
+	if hash := pod.Labels[v1alpha1.LabelKeyConfigHash]; hash != "" && hash != expected.Labels[v1alpha1.LabelKeyConfigHash] {
+		return "config"
+	}
+	return ""
+}
+
+// IsPodRestartAllowed returns whether the group allows the outdated pod of an instance to be recreated
+func IsPodRestartAllowed(obj client.Object) bool {
+	return obj.GetAnnotations()[v1alpha1.AnnoKeyRestartPod] == "true"
+}
+
+// PodHost returns the host of a pod advertised to others, i.e. the DNS name of the pod in its headless service.
+// If the pod uses the network of its node, the pod ip is advertised instead,
+// it's a shell variable expanded by the start script.
+// The returned host can be joined with a port by pdapi.JoinHostPort.
+func PodHost(cluster *v1alpha1.Cluster, network *v1alpha1.Network, pod, service string) string {
+	if network.IsHost() {
+		if network.IsIPv6() {
+			// The ip is only known at runtime, it's enclosed in brackets here
+			return "[${" + podIPEnv + "}]"
+		}
+		return "${" + podIPEnv + "}"
+	}
+	return pod + "." + ServiceHost(cluster, service)
+}
+
+// ListenHost returns the wildcard host listened by a pod
+func ListenHost(network *v1alpha1.Network) string {
+	if network.IsIPv6() {
+		return "::"
+	}
+	return "0.0.0.0"
+}
+
+// ServiceHost returns the DNS name of a service of the cluster.
+// The name is fully qualified if the cluster domain is specified.
+func ServiceHost(cluster *v1alpha1.Cluster, service string) string {
+	if cluster.Spec.ClusterDomain == "" {
+		return service
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", service, cluster.Namespace, cluster.Spec.ClusterDomain)
+}
+
+// ConfigMapName returns the name of the ConfigMap of an instance
+func ConfigMapName(instance client.Object) string {
+	return fmt.Sprintf("%s-config", instance.GetName())
+}
+
+// PVCName returns the name of a PVC of an instance
+func PVCName(instance client.Object, volName string) string {
+	return fmt.Sprintf("%s-%s", instance.GetName(), volName)
+}
+
+// labelsOf returns labels of resources managed by an instance
+func labelsOf(instance Instance) map[string]string {
+	return InstanceLabels(instance.ClusterName(), instance.Component(),
+		instance.GetLabels()[v1alpha1.LabelKeyGroup], instance.GetName())
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+func TestAdvertiseAddress(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName   string
+		domain     string
+		network    v1alpha1.Network
+		wantListen string
+		wantAddr   string
+	}{{
+		caseName:   "default",
+		wantListen: "0.0.0.0:20160",
+		wantAddr:   "basic-tikv-0.basic-tikv:20160",
+	}, {
+		caseName:   "cluster domain",
+		domain:     "cluster.local",
+		wantListen: "0.0.0.0:20160",
+		wantAddr:   "basic-tikv-0.basic-tikv.ns.svc.cluster.local:20160",
+	}, {
+		caseName:   "ipv6",
+		network:    v1alpha1.Network{IPFamily: corev1.IPv6Protocol},
+		wantListen: "[::]:20160",
+		wantAddr:   "basic-tikv-0.basic-tikv:20160",
+	}, {
+		caseName:   "host network",
+		network:    v1alpha1.Network{Mode: v1alpha1.NetworkModeHost},
+		wantListen: "0.0.0.0:20160",
+		wantAddr:   "${POD_IP}:20160",
+	}, {
+		caseName:   "host network with ipv6",
+		network:    v1alpha1.Network{Mode: v1alpha1.NetworkModeHost, IPFamily: corev1.IPv6Protocol},
+		wantListen: "[::]:20160",
+		wantAddr:   "[${POD_IP}]:20160",
+	}}
+
+	for _, tc := range tcs {
+		cluster := &v1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns"},
+			Spec:       v1alpha1.ClusterSpec{ClusterDomain: tc.domain},
+		}
+		g.Expect(pdapi.JoinHostPort(ListenHost(&tc.network), 20160)).To(Equal(tc.wantListen), tc.caseName)
+		g.Expect(pdapi.JoinHostPort(PodHost(cluster, &tc.network, "basic-tikv-0", "basic-tikv"), 20160)).To(Equal(tc.wantAddr), tc.caseName)
+	}
+}
+
+func TestPodChange(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	expected := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+		v1alpha1.LabelKeyPodSpecHash: "spec-new",
+		v1alpha1.LabelKeyConfigHash:  "config-new",
+	}}}
+
+	tcs := []struct {
+		caseName string
+		pod      *corev1.Pod
+		want     string
+	}{{
+		caseName: "pod is not created",
+	}, {
+		caseName: "pod is up to date",
+		pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			v1alpha1.LabelKeyPodSpecHash: "spec-new",
+			v1alpha1.LabelKeyConfigHash:  "config-new",
+		}}},
+	}, {
+		caseName: "spec is changed",
+		pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			v1alpha1.LabelKeyPodSpecHash: "spec-old",
+			v1alpha1.LabelKeyConfigHash:  "config-old",
+		}}},
+		want: "spec",
+	}, {
+		caseName: "config is changed",
+		pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			v1alpha1.LabelKeyPodSpecHash: "spec-new",
+			v1alpha1.LabelKeyConfigHash:  "config-old",
+		}}},
+		want: "config",
+	}, {
+		caseName: "pod of an older operator has no hashes",
+		pod:      &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}}},
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(podChange(tc.pod, expected)).To(Equal(tc.want), tc.caseName)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+// MonitoringGroupVersion is the group version of Prometheus Operator CRDs
+var MonitoringGroupVersion = schema.GroupVersion{Group: "monitoring.coreos.com", Version: "v1"}
+
+const (
+	// MetricsPath is the path of metrics of both PD and TiKV
+	MetricsPath = "/metrics"
+
+	// Annotations recognized by the default Prometheus kubernetes_sd configs
+	AnnoKeyPrometheusScrape = "prometheus.io/scrape"
+	AnnoKeyPrometheusPort   = "prometheus.io/port"
+	AnnoKeyPrometheusPath   = "prometheus.io/path"
+)
+
+// MonitorKind returns the kind of monitor of the monitoring spec
+func MonitorKind(m *v1alpha1.Monitoring) v1alpha1.MonitorKind {
+	if m.Kind == "" {
+		return v1alpha1.MonitorKindPodMonitor
+	}
+	return m.Kind
+}
+
+// IsMonitorInstalled returns whether the CRD of a kind of monitor is installed
+func IsMonitorInstalled(mapper meta.RESTMapper, kind v1alpha1.MonitorKind) (bool, error) {
+	_, err := mapper.RESTMapping(MonitoringGroupVersion.WithKind(string(kind)).GroupKind(), MonitoringGroupVersion.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ScrapeAnnotations returns the annotations which advertise metrics of a pod to Prometheus
+func ScrapeAnnotations(port int32) map[string]string {
+	return map[string]string{
+		AnnoKeyPrometheusScrape: "true",
+		AnnoKeyPrometheusPort:   strconv.Itoa(int(port)),
+		AnnoKeyPrometheusPath:   MetricsPath,
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/task"
+)
+
+// PDBName returns the name of the PodDisruptionBudget of a component in a cluster
+func PDBName(cluster, component string) string {
+	return fmt.Sprintf("%s-%s", cluster, component)
+}
+
+// TaskPDB applies the PodDisruptionBudget which protects pods of the component from voluntary disruptions,
+// e.g. evictions of kubectl drain.
+// One budget covers all groups of the component in the cluster, because the quorum of PD members and
+// region replicas spans groups. It's owned by the cluster and every group computes the same budget.
+// The budget is tightened by one while a rollout of any group is in progress because the operator restarts
+// an instance itself. If the budget can't be computed, e.g. PD is unavailable, the current one is kept.
+func (r *GroupReconciler[G, I]) TaskPDB(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("PDB", func(ctx context.Context) task.Result {
+		group := state.Group
+		groups, err := r.Adapter.ListGroups(ctx, r.Client, group.GetNamespace(), group.ClusterName())
+		if err != nil {
+			return task.Fail().With("can't list groups: %w", err)
+		}
+		instances, err := r.Adapter.ListInstances(ctx, r.Client, group.GetNamespace(), client.MatchingLabels{
+			v1alpha1.LabelKeyCluster:   group.ClusterName(),
+			v1alpha1.LabelKeyComponent: group.Component(),
+		})
+		if err != nil {
+			return task.Fail().With("can't list instances: %w", err)
+		}
+
+		pdc := PDClient(r.PDControl, state.Cluster)
+		maxUnavailable, err := r.Adapter.MaxUnavailable(pdc, groups, instances)
+		if err != nil {
+			r.Recorder.Eventf(group, corev1.EventTypeWarning, v1alpha1.ReasonPDAPIError,
+				"can't compute max unavailable instances: %v", err)
+			return task.Complete().With("pdb is kept: %v", err)
+		}
+		if r.isRollingOut(groups, instances) {
+			maxUnavailable--
+		}
+		if maxUnavailable < 0 {
+			maxUnavailable = 0
+		}
+
+		pdb := newPDB(group.ClusterName(), group.Component(), group.GetNamespace(), maxUnavailable)
+		if err := controllerutil.SetControllerReference(state.Cluster, pdb, r.Scheme); err != nil {
+			return task.Fail().With("can't set owner reference of pdb: %w", err)
+		}
+		if _, err := Apply(ctx, r.Client, r.Recorder, group, pdb); err != nil {
+			return task.Fail().With("can't apply pdb: %w", err)
+		}
+		return task.Complete().With("pdb allows %d unavailable instances", maxUnavailable)
+	})
+}
+
+// isRollingOut returns whether some instances of the groups are being updated or deleted
+func (r *GroupReconciler[G, I]) isRollingOut(groups []G, instances []I) bool {
+	revisions := map[string]string{}
+	for _, group := range groups {
+		revisions[group.GetName()] = Hash(r.Adapter.Template(group))
+	}
+	for _, instance := range instances {
+		labels := instance.GetLabels()
+		if !instance.GetDeletionTimestamp().IsZero() ||
+			labels[v1alpha1.LabelKeyInstanceRevisionHash] != revisions[labels[v1alpha1.LabelKeyGroup]] {
+			return true
+		}
+	}
+	return false
+}
+
+func newPDB(cluster, component, namespace string, maxUnavailable int32) *policyv1.PodDisruptionBudget {
+	mu := intstr.FromInt32(maxUnavailable)
+	selector := map[string]string{
+		v1alpha1.LabelKeyCluster:   cluster,
+		v1alpha1.LabelKeyComponent: component,
+	}
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PDBName(cluster, component),
+			Namespace: namespace,
+			Labels: map[string]string{
+				v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
+				v1alpha1.LabelKeyCluster:   cluster,
+				v1alpha1.LabelKeyComponent: component,
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &mu,
+			Selector:       &metav1.LabelSelector{MatchLabels: selector},
+		},
+	}
+}
+
+// QuorumMaxUnavailable returns how many of n voters can be unavailable without losing the majority
+func QuorumMaxUnavailable(n int32) int32 {
+	if n <= 0 {
+		return 0
+	}
+	return (n - 1) / 2
+}
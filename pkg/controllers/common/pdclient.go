@@ -0,0 +1,41 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+// PDClientPort returns the client port of PD of a cluster, it's the port of the PD url in the cluster status.
+// The default port is returned if the url is not resolved yet.
+func PDClientPort(cluster *v1alpha1.Cluster) int32 {
+	u, err := url.Parse(cluster.Status.PD)
+	if err != nil || u.Port() == "" {
+		return v1alpha1.DefaultPDPortClient
+	}
+	port, err := strconv.ParseInt(u.Port(), 10, 32)
+	if err != nil {
+		return v1alpha1.DefaultPDPortClient
+	}
+	return int32(port)
+}
+
+// PDClient returns the client of PD of a cluster
+func PDClient(pdc pdapi.PDControlInterface, cluster *v1alpha1.Cluster) pdapi.PDClient {
+	return pdc.GetPDClient(pdapi.Namespace(cluster.Namespace), cluster.Name, false, pdapi.ClientPort(PDClientPort(cluster)))
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestPDClientPort(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		url      string
+		want     int32
+	}{{
+		caseName: "not resolved",
+		want:     v1alpha1.DefaultPDPortClient,
+	}, {
+		caseName: "legacy url",
+		url:      "http://pd:2379",
+		want:     2379,
+	}, {
+		caseName: "custom port",
+		url:      "http://basic-pd.default:12379",
+		want:     12379,
+	}, {
+		caseName: "no port",
+		url:      "http://basic-pd.default",
+		want:     v1alpha1.DefaultPDPortClient,
+	}, {
+		caseName: "invalid url",
+		url:      "://",
+		want:     v1alpha1.DefaultPDPortClient,
+	}}
+
+	for _, tc := range tcs {
+		cluster := &v1alpha1.Cluster{Status: v1alpha1.ClusterStatus{PD: tc.url}}
+		g.Expect(PDClientPort(cluster)).To(Equal(tc.want), tc.caseName)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+// HTTPGetProbe returns a probe which gets an http path of a port
+func HTTPGetProbe(path string, port int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: path,
+				Port: intstr.FromInt32(port),
+			},
+		},
+	}
+}
+
+// TCPSocketProbe returns a probe which connects to a port
+func TCPSocketProbe(port int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt32(port),
+			},
+		},
+	}
+}
+
+// OverrideProbe overrides settings of a default probe by the specified ones
+func OverrideProbe(probe *corev1.Probe, settings *v1alpha1.ProbeSettings) *corev1.Probe {
+	if settings == nil {
+		return probe
+	}
+	if settings.InitialDelaySeconds != nil {
+		probe.InitialDelaySeconds = *settings.InitialDelaySeconds
+	}
+	if settings.PeriodSeconds != nil {
+		probe.PeriodSeconds = *settings.PeriodSeconds
+	}
+	if settings.TimeoutSeconds != nil {
+		probe.TimeoutSeconds = *settings.TimeoutSeconds
+	}
+	if settings.SuccessThreshold != nil {
+		probe.SuccessThreshold = *settings.SuccessThreshold
+	}
+	if settings.FailureThreshold != nil {
+		probe.FailureThreshold = *settings.FailureThreshold
+	}
+	return probe
+}
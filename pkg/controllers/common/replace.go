@@ -0,0 +1,124 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/task"
+)
+
+// TaskReplace replaces outdated instances whose volumes can't be changed in place,
+// e.g. the storage class is changed or a volume is shrunk.
+// Such an instance is marked with AnnoKeyReplacing and is no longer counted as a replica,
+// so a new instance of the update revision is created by TaskScale.
+// The marked instance is prepared by PreDelete after all other instances are available,
+// and deleted once it's deletable. At most ReplaceConcurrency instances are replaced at the same time.
+func (r *GroupReconciler[G, I]) TaskReplace(state *GroupState[G, I]) task.Task {
+	return task.NameTaskFunc("Replace", func(ctx context.Context) task.Result {
+		group := state.Group
+		concurrency := r.Adapter.ReplaceConcurrency(group)
+		if concurrency <= 0 {
+			return task.Complete().With("replacement is disabled")
+		}
+
+		replacing := []I{}
+		others := []I{}
+		candidates := []I{}
+		reasons := map[string]string{}
+		for _, instance := range state.Instances {
+			if !instance.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			if IsReplacing(instance) {
+				replacing = append(replacing, instance)
+				continue
+			}
+			if r.Adapter.IsPreDeleted(instance) {
+				continue
+			}
+			others = append(others, instance)
+			if reason := r.replaceReason(state, instance); reason != "" {
+				candidates = append(candidates, instance)
+				reasons[instance.GetName()] = reason
+			}
+		}
+
+		// Replaced instances are only prepared for deletion after their replacements are available
+		othersAvailable := int32(len(others)) >= group.GetReplicas()
+		now := time.Now()
+		for _, instance := range others {
+			if available, _ := IsInstanceAvailable(instance.GetCommonStatus().Conditions, r.Adapter.MinReadySeconds(), now); !available {
+				othersAvailable = false
+				break
+			}
+		}
+		if othersAvailable {
+			for _, instance := range replacing {
+				if err := r.Adapter.PreDelete(ctx, r.Client, r.Recorder, group, instance); err != nil {
+					return task.Fail().With("can't prepare deletion of instance %s: %w", instance.GetName(), err)
+				}
+			}
+		}
+		if _, err := r.deleteIfDeletable(ctx, group, replacing); err != nil {
+			return task.Fail().With("%w", err)
+		}
+
+		// Mark more instances to be replaced up to the concurrency
+		SortForScaleIn(state.InstancePrefix(), state.Group.GetScaleInInstances(), candidates)
+		for i := 0; i < len(candidates) && int32(len(replacing)) < concurrency; i++ {
+			instance := candidates[i]
+			patch := client.MergeFrom(instance.DeepCopyObject().(client.Object))
+			annotations := instance.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[v1alpha1.AnnoKeyReplacing] = "true"
+			instance.SetAnnotations(annotations)
+			if err := r.Patch(ctx, instance, patch); err != nil {
+				return task.Fail().With("can't mark instance %s to be replaced: %w", instance.GetName(), err)
+			}
+			r.Log.Info("replacing instance", "name", instance.GetName(), "reason", reasons[instance.GetName()])
+			r.Recorder.Eventf(group, corev1.EventTypeNormal, v1alpha1.ReasonInstanceReplacing,
+				"instance %s is being replaced: %s", instance.GetName(), reasons[instance.GetName()])
+			replacing = append(replacing, instance)
+		}
+
+		if len(replacing) == 0 {
+			return task.Complete().With("no instance needs to be replaced")
+		}
+		return task.Complete().With("%d instances are being replaced", len(replacing))
+	})
+}
+
+// replaceReason returns why an outdated instance has to be replaced instead of being updated in place.
+// It's empty if the instance can be updated in place or replacement is disabled.
+func (r *GroupReconciler[G, I]) replaceReason(state *GroupState[G, I], instance I) string {
+	if r.Adapter.ReplaceConcurrency(state.Group) <= 0 ||
+		instance.GetLabels()[v1alpha1.LabelKeyInstanceRevisionHash] == state.UpdateRevision {
+		return ""
+	}
+	desired := r.Adapter.NewInstanceFromGroup(state.Group, instance.GetName())
+	return volumeReplaceReason(instance.GetVolumes(), desired.GetVolumes())
+}
+
+// IsReplacing returns whether an instance is being replaced by a new instance of its group
+func IsReplacing(obj client.Object) bool {
+	return obj.GetAnnotations()[v1alpha1.AnnoKeyReplacing] == "true"
+}
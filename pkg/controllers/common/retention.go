@@ -0,0 +1,205 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/task"
+)
+
+// PVCRetentionPolicy returns the retention policy of PVCs of the instance.
+// The policy of the group overrides the one of the cluster, the default is Delete.
+func (s *InstanceState[G, I]) PVCRetentionPolicy() v1alpha1.PVCRetentionPolicy {
+	if s.GroupFound && s.Group.GetPVCRetentionPolicy() != "" {
+		return s.Group.GetPVCRetentionPolicy()
+	}
+	if s.Cluster.Spec.PVCRetentionPolicy != "" {
+		return s.Cluster.Spec.PVCRetentionPolicy
+	}
+	return v1alpha1.PVCRetentionPolicyDelete
+}
+
+// shouldRetainPVC returns whether a PVC with the policy is retained when its instance is deleted
+func shouldRetainPVC(policy v1alpha1.PVCRetentionPolicy, groupOrClusterDeleted bool) bool {
+	switch policy {
+	case v1alpha1.PVCRetentionPolicyRetain:
+		return true
+	case v1alpha1.PVCRetentionPolicyRetainOnClusterDelete:
+		return groupOrClusterDeleted
+	default:
+		return false
+	}
+}
+
+// TaskFinalizer adds the finalizer to the instance so that its PVCs can be retained before it's deleted
+func (r *InstanceReconciler[G, I]) TaskFinalizer(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("Finalizer", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		if !controllerutil.AddFinalizer(instance, v1alpha1.Finalizer) {
+			return task.Complete().With("finalizer is added")
+		}
+		if err := r.Update(ctx, instance); err != nil {
+			return task.Fail().With("can't add finalizer: %w", err)
+		}
+		return task.Complete().With("finalizer is added")
+	})
+}
+
+// TaskFinalize retains PVCs of a deleting instance according to the policy recorded on each PVC,
+// then removes the finalizer. A retained PVC is labeled with LabelKeyRetained and
+// the owner reference to the instance is removed, so it's not garbage collected.
+func (r *InstanceReconciler[G, I]) TaskFinalize(state *InstanceState[G, I]) task.Task {
+	return task.NameTaskFunc("Finalize", func(ctx context.Context) task.Result {
+		instance := state.Instance
+		if !controllerutil.ContainsFinalizer(instance, v1alpha1.Finalizer) {
+			return task.Complete().With("finalizer is removed")
+		}
+
+		groupOrClusterDeleted, err := r.isGroupOrClusterDeleted(ctx, instance)
+		if err != nil {
+			return task.Fail().With("can't check deletion of group and cluster: %w", err)
+		}
+
+		var pvcList corev1.PersistentVolumeClaimList
+		if err := r.List(ctx, &pvcList, client.InNamespace(instance.GetNamespace()), client.MatchingLabels{
+			v1alpha1.LabelKeyCluster:   instance.ClusterName(),
+			v1alpha1.LabelKeyComponent: instance.Component(),
+			v1alpha1.LabelKeyInstance:  instance.GetName(),
+		}); err != nil {
+			return task.Fail().With("can't list pvcs: %w", err)
+		}
+		for i := range pvcList.Items {
+			pvc := &pvcList.Items[i]
+			policy := v1alpha1.PVCRetentionPolicy(pvc.Annotations[v1alpha1.AnnoKeyPVCRetentionPolicy])
+			if !metav1.IsControlledBy(pvc, instance) || !shouldRetainPVC(policy, groupOrClusterDeleted) {
+				continue
+			}
+			if err := r.retainPVC(ctx, instance, pvc); err != nil {
+				return task.Fail().With("can't retain pvc %s: %w", pvc.Name, err)
+			}
+			r.Log.Info("PVC retained", "name", pvc.Name, "policy", policy)
+			r.Recorder.Eventf(instance, corev1.EventTypeNormal, v1alpha1.ReasonPVCRetained,
+				"pvc %s is retained by policy %s", pvc.Name, policy)
+		}
+
+		controllerutil.RemoveFinalizer(instance, v1alpha1.Finalizer)
+		if err := r.Update(ctx, instance); err != nil {
+			return task.Fail().With("can't remove finalizer: %w", err)
+		}
+		return task.Complete().With("finalizer is removed")
+	})
+}
+
+// isGroupOrClusterDeleted returns whether the group or the cluster of the instance is deleted or being deleted
+func (r *InstanceReconciler[G, I]) isGroupOrClusterDeleted(ctx context.Context, instance I) (bool, error) {
+	objs := []client.Object{r.Adapter.NewGroup(), &v1alpha1.Cluster{}}
+	names := []string{instance.GetLabels()[v1alpha1.LabelKeyGroup], instance.ClusterName()}
+	for i, obj := range objs {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: instance.GetNamespace(), Name: names[i]}, obj); err != nil {
+			if errors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		if !obj.GetDeletionTimestamp().IsZero() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// retainPVC removes the owner reference to the instance from a PVC and labels it as retained.
+// A PVC of a scaled-in instance is marked with AnnoKeyScaledIn.
+func (r *InstanceReconciler[G, I]) retainPVC(ctx context.Context, instance I, pvc *corev1.PersistentVolumeClaim) error {
+	patch := client.MergeFrom(pvc.DeepCopy())
+	refs := []metav1.OwnerReference{}
+	for _, ref := range pvc.OwnerReferences {
+		if ref.UID != instance.GetUID() {
+			refs = append(refs, ref)
+		}
+	}
+	pvc.OwnerReferences = refs
+	if pvc.Labels == nil {
+		pvc.Labels = map[string]string{}
+	}
+	pvc.Labels[v1alpha1.LabelKeyRetained] = "true"
+	if IsScaledIn(instance) {
+		if pvc.Annotations == nil {
+			pvc.Annotations = map[string]string{}
+		}
+		pvc.Annotations[v1alpha1.AnnoKeyScaledIn] = "true"
+	}
+	return r.Patch(ctx, pvc, patch)
+}
+
+// readoptPVC removes the retained label of a PVC left by a deleted instance with the same name and volume.
+// The owner reference is set again when the PVC is applied.
+// A PVC retained by another cluster or component is never adopted, neither is a PVC of a scaled-in instance
+// because its store or member is removed from the cluster and can't be reused.
+func (r *InstanceReconciler[G, I]) readoptPVC(ctx context.Context, instance I, volName string, pvc *corev1.PersistentVolumeClaim) error {
+	if pvc.Labels[v1alpha1.LabelKeyCluster] != instance.ClusterName() ||
+		pvc.Labels[v1alpha1.LabelKeyComponent] != instance.Component() ||
+		pvc.Labels[v1alpha1.LabelKeyVolumeName] != volName {
+		return fmt.Errorf("pvc %s is retained by volume %s of %s in cluster %s", pvc.Name,
+			pvc.Labels[v1alpha1.LabelKeyVolumeName], pvc.Labels[v1alpha1.LabelKeyComponent], pvc.Labels[v1alpha1.LabelKeyCluster])
+	}
+	if IsScaledIn(pvc) {
+		return fmt.Errorf("pvc %s is retained by a scaled-in instance whose data can't be reused, delete it first", pvc.Name)
+	}
+	patch := client.MergeFrom(pvc.DeepCopy())
+	delete(pvc.Labels, v1alpha1.LabelKeyRetained)
+	if err := r.Patch(ctx, pvc, patch); err != nil {
+		return err
+	}
+	r.Log.Info("PVC re-adopted", "name", pvc.Name)
+	r.Recorder.Eventf(instance, corev1.EventTypeNormal, v1alpha1.ReasonPVCReadopted, "pvc %s is re-adopted", pvc.Name)
+	return nil
+}
+
+// IsScaledIn returns whether an instance is deleted by its group on scale in or replacement,
+// or a PVC is retained by such an instance
+func IsScaledIn(obj client.Object) bool {
+	return obj.GetAnnotations()[v1alpha1.AnnoKeyScaledIn] == "true"
+}
+
+// ScaledInPVCInstances returns names of scaled-in instances of a group whose PVCs are retained.
+// The names are not used by new instances, because such PVCs are never re-adopted.
+func ScaledInPVCInstances(ctx context.Context, c client.Client, group Group) ([]string, error) {
+	var pvcList corev1.PersistentVolumeClaimList
+	if err := c.List(ctx, &pvcList, client.InNamespace(group.GetNamespace()), client.MatchingLabels{
+		v1alpha1.LabelKeyCluster:   group.ClusterName(),
+		v1alpha1.LabelKeyComponent: group.Component(),
+		v1alpha1.LabelKeyGroup:     group.GetName(),
+		v1alpha1.LabelKeyRetained:  "true",
+	}); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for i := range pvcList.Items {
+		if IsScaledIn(&pvcList.Items[i]) {
+			names = append(names, pvcList.Items[i].Labels[v1alpha1.LabelKeyInstance])
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestPVCRetentionPolicy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName      string
+		cluster       v1alpha1.PVCRetentionPolicy
+		group         v1alpha1.PVCRetentionPolicy
+		groupFound    bool
+		want          v1alpha1.PVCRetentionPolicy
+		wantOnScaleIn bool
+		wantOnDelete  bool
+	}{{
+		caseName:   "default",
+		groupFound: true,
+		want:       v1alpha1.PVCRetentionPolicyDelete,
+	}, {
+		caseName:      "cluster policy",
+		cluster:       v1alpha1.PVCRetentionPolicyRetain,
+		groupFound:    true,
+		want:          v1alpha1.PVCRetentionPolicyRetain,
+		wantOnScaleIn: true,
+		wantOnDelete:  true,
+	}, {
+		caseName:     "group overrides cluster",
+		cluster:      v1alpha1.PVCRetentionPolicyRetain,
+		group:        v1alpha1.PVCRetentionPolicyRetainOnClusterDelete,
+		groupFound:   true,
+		want:         v1alpha1.PVCRetentionPolicyRetainOnClusterDelete,
+		wantOnDelete: true,
+	}, {
+		caseName: "group is not found",
+		cluster:  v1alpha1.PVCRetentionPolicyDelete,
+		group:    v1alpha1.PVCRetentionPolicyRetain,
+		want:     v1alpha1.PVCRetentionPolicyDelete,
+	}}
+
+	for _, tc := range tcs {
+		state := &InstanceState[*v1alpha1.TiKVGroup, *v1alpha1.TiKV]{
+			Cluster:    &v1alpha1.Cluster{Spec: v1alpha1.ClusterSpec{PVCRetentionPolicy: tc.cluster}},
+			Group:      &v1alpha1.TiKVGroup{Spec: v1alpha1.TiKVGroupSpec{PVCRetentionPolicy: tc.group}},
+			GroupFound: tc.groupFound,
+		}
+		policy := state.PVCRetentionPolicy()
+		g.Expect(policy).To(Equal(tc.want), tc.caseName)
+		g.Expect(shouldRetainPVC(policy, false)).To(Equal(tc.wantOnScaleIn), tc.caseName)
+		g.Expect(shouldRetainPVC(policy, true)).To(Equal(tc.wantOnDelete), tc.caseName)
+	}
+}
+
+func TestRetainScaledInPVC(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	tcs := []struct {
+		caseName      string
+		scaledIn      bool
+		wantReadopted bool
+		wantScaledIn  []string
+	}{{
+		caseName:      "instance is deleted accidentally",
+		wantReadopted: true,
+		wantScaledIn:  []string{},
+	}, {
+		caseName:     "instance is scaled in",
+		scaledIn:     true,
+		wantScaledIn: []string{"basic-tikv-1"},
+	}}
+
+	for _, tc := range tcs {
+		group := &v1alpha1.TiKVGroup{ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "ns"}}
+		group.Spec.Cluster.Name = "basic"
+		tikv := &v1alpha1.TiKV{ObjectMeta: metav1.ObjectMeta{Name: "basic-tikv-1", Namespace: "ns", UID: "uid-1"}}
+		tikv.Spec.Cluster.Name = "basic"
+		tikv.Labels = map[string]string{v1alpha1.LabelKeyGroup: "basic"}
+		if tc.scaledIn {
+			tikv.Annotations = map[string]string{v1alpha1.AnnoKeyScaledIn: "true"}
+		}
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:      PVCName(tikv, "data"),
+			Namespace: "ns",
+			Labels:    labelsOf(tikv),
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "core.tikv.org/v1alpha1", Kind: "TiKV", Name: tikv.Name, UID: tikv.UID,
+			}},
+		}}
+		pvc.Labels[v1alpha1.LabelKeyVolumeName] = "data"
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pvc).Build()
+		r := &InstanceReconciler[*v1alpha1.TiKVGroup, *v1alpha1.TiKV]{
+			Client:   c,
+			Log:      logr.Discard(),
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		g.Expect(r.retainPVC(context.Background(), tikv, pvc)).To(Succeed(), tc.caseName)
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(pvc), pvc)).To(Succeed(), tc.caseName)
+		g.Expect(pvc.OwnerReferences).To(BeEmpty(), tc.caseName)
+		g.Expect(pvc.Labels[v1alpha1.LabelKeyRetained]).To(Equal("true"), tc.caseName)
+		g.Expect(IsScaledIn(pvc)).To(Equal(tc.scaledIn), tc.caseName)
+
+		names, err := ScaledInPVCInstances(context.Background(), c, group)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(names).To(Equal(tc.wantScaledIn), tc.caseName)
+
+		// A new instance with the same name
+		newTiKV := &v1alpha1.TiKV{ObjectMeta: metav1.ObjectMeta{Name: tikv.Name, Namespace: "ns", UID: "uid-2"}}
+		newTiKV.Spec.Cluster.Name = "basic"
+		err = r.readoptPVC(context.Background(), newTiKV, "data", pvc)
+		if !tc.wantReadopted {
+			g.Expect(err).To(HaveOccurred(), tc.caseName)
+			continue
+		}
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(pvc), pvc)).To(Succeed(), tc.caseName)
+		g.Expect(pvc.Labels).NotTo(HaveKey(v1alpha1.LabelKeyRetained), tc.caseName)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+// InstanceOrdinal returns the ordinal of an instance named <prefix><ordinal>
+func InstanceOrdinal(prefix, name string) (int, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil || ordinal < 0 {
+		return 0, false
+	}
+	return ordinal, true
+}
+
+// NextInstanceNames returns names of n new instances.
+// The lowest ordinals which are not used by existing instances are chosen,
+// so gaps left by selective scale-in are filled first.
+func NextInstanceNames(prefix string, existing []string, n int) []string {
+	used := map[int]struct{}{}
+	for _, name := range existing {
+		if ordinal, ok := InstanceOrdinal(prefix, name); ok {
+			used[ordinal] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, n)
+	for ordinal := 0; len(names) < n; ordinal++ {
+		if _, ok := used[ordinal]; ok {
+			continue
+		}
+		names = append(names, fmt.Sprintf("%s%d", prefix, ordinal))
+	}
+	return names
+}
+
+// SortForScaleIn sorts instances in the order they should be deleted when scaling in.
+// Instances annotated with AnnoKeyScaleInFirst or listed in scaleInInstances of the group come first,
+// then the highest ordinals.
+func SortForScaleIn[T client.Object](prefix string, scaleInInstances []string, instances []T) {
+	listed := sets.New(scaleInInstances...)
+	isScaleInFirst := func(obj client.Object) bool {
+		return obj.GetAnnotations()[v1alpha1.AnnoKeyScaleInFirst] == "true" || listed.Has(obj.GetName())
+	}
+	sort.SliceStable(instances, func(i, j int) bool {
+		fi, fj := isScaleInFirst(instances[i]), isScaleInFirst(instances[j])
+		if fi != fj {
+			return fi
+		}
+		oi, _ := InstanceOrdinal(prefix, instances[i].GetName())
+		oj, _ := InstanceOrdinal(prefix, instances[j].GetName())
+		return oi > oj
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestNextInstanceNames(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		existing []string
+		n        int
+		want     []string
+	}{{
+		caseName: "empty group",
+		existing: nil,
+		n:        3,
+		want:     []string{"kv-tikv-0", "kv-tikv-1", "kv-tikv-2"},
+	}, {
+		caseName: "contiguous",
+		existing: []string{"kv-tikv-0", "kv-tikv-1"},
+		n:        1,
+		want:     []string{"kv-tikv-2"},
+	}, {
+		caseName: "fill gaps first",
+		existing: []string{"kv-tikv-0", "kv-tikv-2", "kv-tikv-4"},
+		n:        3,
+		want:     []string{"kv-tikv-1", "kv-tikv-3", "kv-tikv-5"},
+	}, {
+		caseName: "ignore foreign names",
+		existing: []string{"other-tikv-0", "kv-tikv-x"},
+		n:        1,
+		want:     []string{"kv-tikv-0"},
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(NextInstanceNames("kv-tikv-", tc.existing, tc.n)).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestSortForScaleIn(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newTiKV := func(name string, first bool) *v1alpha1.TiKV {
+		tikv := &v1alpha1.TiKV{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if first {
+			tikv.Annotations = map[string]string{v1alpha1.AnnoKeyScaleInFirst: "true"}
+		}
+		return tikv
+	}
+
+	tcs := []struct {
+		caseName         string
+		scaleInInstances []string
+		want             []string
+	}{{
+		caseName: "annotated instance",
+		want:     []string{"kv-tikv-1", "kv-tikv-3", "kv-tikv-2", "kv-tikv-0"},
+	}, {
+		caseName:         "annotated and listed instances",
+		scaleInInstances: []string{"kv-tikv-0", "kv-tikv-9"},
+		want:             []string{"kv-tikv-1", "kv-tikv-0", "kv-tikv-3", "kv-tikv-2"},
+	}}
+
+	for _, tc := range tcs {
+		tikvs := []*v1alpha1.TiKV{
+			newTiKV("kv-tikv-0", false),
+			newTiKV("kv-tikv-1", true),
+			newTiKV("kv-tikv-3", false),
+			newTiKV("kv-tikv-2", false),
+		}
+		SortForScaleIn("kv-tikv-", tc.scaleInInstances, tikvs)
+
+		names := []string{}
+		for _, tikv := range tikvs {
+			names = append(names, tikv.Name)
+		}
+		g.Expect(names).To(Equal(tc.want), tc.caseName)
+	}
+}
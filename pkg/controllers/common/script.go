@@ -0,0 +1,100 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+// scriptFuncs are shell functions used by start scripts.
+// A dependency is not waited if no tool in the image can check it.
+const scriptFuncs = `wait_dns() {
+  if command -v getent >/dev/null 2>&1; then
+    until getent hosts "$1" >/dev/null 2>&1; do
+      echo "waiting for $1 to be resolvable"
+      sleep 1
+    done
+  elif command -v nslookup >/dev/null 2>&1; then
+    until nslookup "$1" >/dev/null 2>&1; do
+      echo "waiting for $1 to be resolvable"
+      sleep 1
+    done
+  else
+    echo "getent and nslookup are not found, $1 is not waited"
+  fi
+}
+
+wait_http() {
+  if command -v curl >/dev/null 2>&1; then
+    until curl -fs -o /dev/null --max-time 3 "$1"; do
+      echo "waiting for $1 to be available"
+      sleep 1
+    done
+  elif command -v wget >/dev/null 2>&1; then
+    until wget -q -T 3 -O /dev/null "$1"; do
+      echo "waiting for $1 to be available"
+      sleep 1
+    done
+  else
+    echo "curl and wget are not found, $1 is not waited"
+  fi
+}
+`
+
+// StartScript starts a server after its dependencies are ready, so it doesn't crash while the cluster is bootstrapping.
+// All settings managed by the operator are passed to the server by the script, users can't set them in the config.
+type StartScript struct {
+	// WaitDNS are hosts which should be resolvable before the server is started, e.g. the advertised host
+	WaitDNS []string
+	// WaitHTTP are urls which should respond before the server is started, e.g. the health api of PD
+	WaitHTTP []string
+	// Command is the server binary and its args.
+	// Shell variables in args are expanded, e.g. ${POD_IP}.
+	Command []string
+}
+
+// Render returns the content of the script
+func (s *StartScript) Render() string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n# Generated by tikv-operator, changes are overwritten\nset -e\n\n")
+	b.WriteString(scriptFuncs)
+	b.WriteString("\n")
+	for _, host := range s.WaitDNS {
+		fmt.Fprintf(&b, "wait_dns %s\n", shellQuote(host))
+	}
+	for _, url := range s.WaitHTTP {
+		fmt.Fprintf(&b, "wait_http %s\n", shellQuote(url))
+	}
+	b.WriteString("\nexec")
+	for _, arg := range s.Command {
+		fmt.Fprintf(&b, " \\\n  %s", shellQuote(arg))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// StartScriptPath returns the path of the start script in the container
+func StartScriptPath(configDir string) string {
+	return path.Join(configDir, v1alpha1.ConfigMapKeyStartScript)
+}
+
+// shellQuote quotes an arg by double quotes, only shell variables are expanded in it
+func shellQuote(arg string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$(", "\\$(")
+	return `"` + r.Replace(arg) + `"`
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestShellQuote(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		arg      string
+		want     string
+	}{{
+		caseName: "plain",
+		arg:      "--data-dir=/var/lib/tikv",
+		want:     `"--data-dir=/var/lib/tikv"`,
+	}, {
+		caseName: "variable is expanded",
+		arg:      "--advertise-addr=${POD_IP}:20160",
+		want:     `"--advertise-addr=${POD_IP}:20160"`,
+	}, {
+		caseName: "command substitution is escaped",
+		arg:      "--name=$(id)`id`",
+		want:     "\"--name=\\$(id)\\`id\\`\"",
+	}, {
+		caseName: "quote and backslash are escaped",
+		arg:      `a"b\c`,
+		want:     `"a\"b\\c"`,
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(shellQuote(tc.arg)).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestStartScript(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	script := (&StartScript{
+		WaitDNS:  []string{"basic-tikv-0.basic-tikv"},
+		WaitHTTP: []string{"http://basic-pd:2379/pd/api/v1/health"},
+		Command:  []string{"/tikv-server", "--addr=0.0.0.0:20160"},
+	}).Render()
+	g.Expect(script).To(HavePrefix("#!/bin/sh\n"))
+	g.Expect(script).To(ContainSubstring("\nwait_dns \"basic-tikv-0.basic-tikv\"\n"))
+	g.Expect(script).To(ContainSubstring("\nwait_http \"http://basic-pd:2379/pd/api/v1/health\"\n"))
+	g.Expect(script).To(HaveSuffix("\nexec \\\n  \"/tikv-server\" \\\n  \"--addr=0.0.0.0:20160\"\n"))
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+const (
+	// annoKeyDefaultStorageClass marks the default StorageClass of a Kubernetes cluster
+	annoKeyDefaultStorageClass = "storageclass.kubernetes.io/is-default-class"
+
+	// fsResizeRestartDelay is how long to wait for an online file system resize before restarting the pod.
+	// Volumes of CSI drivers which support online expansion are resized by kubelet without a restart.
+	fsResizeRestartDelay = 2 * time.Minute
+)
+
+// volumeResize aggregates the resize progress of PVCs of an instance.
+// Reasons are ordered by severity, the most severe one is reported.
+type volumeResize struct {
+	reason string
+	msgs   []string
+	// fsResizePendingSince is the earliest time a PVC is waiting for a file system resize
+	fsResizePendingSince *metav1.Time
+}
+
+var resizeSeverity = map[string]int{
+	v1alpha1.ReasonVolumesResized:           0,
+	v1alpha1.ReasonVolumeResizing:           1,
+	v1alpha1.ReasonFileSystemResizePending:  2,
+	v1alpha1.ReasonVolumeShrinkForbidden:    3,
+	v1alpha1.ReasonVolumeExpansionForbidden: 3,
+}
+
+func (v *volumeResize) add(reason, msg string) {
+	if v.reason == "" || resizeSeverity[reason] > resizeSeverity[v.reason] {
+		v.reason = reason
+	}
+	v.msgs = append(v.msgs, msg)
+}
+
+// observe records the resize progress of a PVC from its conditions and capacity
+func (v *volumeResize) observe(pvc *corev1.PersistentVolumeClaim, size resource.Quantity) {
+	for _, cond := range pvc.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			v.add(v1alpha1.ReasonFileSystemResizePending, fmt.Sprintf("file system of pvc %s is waiting for resize", pvc.Name))
+			if v.fsResizePendingSince == nil || cond.LastTransitionTime.Before(v.fsResizePendingSince) {
+				since := cond.LastTransitionTime
+				v.fsResizePendingSince = &since
+			}
+			return
+		case corev1.PersistentVolumeClaimResizing:
+			v.add(v1alpha1.ReasonVolumeResizing, fmt.Sprintf("pvc %s is being resized", pvc.Name))
+			return
+		}
+	}
+	capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+	if capacity.Cmp(size) < 0 {
+		v.add(v1alpha1.ReasonVolumeResizing, fmt.Sprintf("pvc %s is being resized to %s", pvc.Name, size.String()))
+	}
+}
+
+// condition returns the VolumesResized condition
+func (v *volumeResize) condition(generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               v1alpha1.CondVolumesResized,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.ReasonVolumesResized,
+		Message:            "all volumes are resized",
+		ObservedGeneration: generation,
+	}
+	if v.reason != "" && v.reason != v1alpha1.ReasonVolumesResized {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v.reason
+		cond.Message = strings.Join(v.msgs, "; ")
+	}
+	return cond
+}
+
+// needRestartPod returns whether the pod should be restarted to complete a file system resize,
+// i.e. the pod is created before the resize and the file system is not resized online in time
+func (v *volumeResize) needRestartPod(pod *corev1.Pod, now time.Time) bool {
+	if pod == nil || !pod.DeletionTimestamp.IsZero() || v.fsResizePendingSince == nil {
+		return false
+	}
+	if pod.CreationTimestamp.After(v.fsResizePendingSince.Time) {
+		return false
+	}
+	return now.Sub(v.fsResizePendingSince.Time) >= fsResizeRestartDelay
+}
+
+// isExpansionAllowed returns whether PVCs of the storage class can be expanded.
+// The default storage class is used if the class name is not specified.
+func isExpansionAllowed(ctx context.Context, c client.Client, className *string) (bool, error) {
+	if className != nil {
+		sc := &storagev1.StorageClass{}
+		if err := c.Get(ctx, client.ObjectKey{Name: *className}, sc); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+	}
+
+	var scList storagev1.StorageClassList
+	if err := c.List(ctx, &scList); err != nil {
+		return false, err
+	}
+	for i := range scList.Items {
+		sc := &scList.Items[i]
+		if sc.Annotations[annoKeyDefaultStorageClass] == "true" {
+			return sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion, nil
+		}
+	}
+	return false, nil
+}
+
+// volumeReplaceReason returns why current volumes can't be changed to the desired ones in place,
+// i.e. the storage class of a volume is changed or a volume is shrunk. It's empty if they can.
+func volumeReplaceReason(current, desired []v1alpha1.Volume) string {
+	reasons := []string{}
+	for i := range desired {
+		want := &desired[i]
+		for j := range current {
+			vol := &current[j]
+			if vol.Name != want.Name {
+				continue
+			}
+			if from, to := storageClassName(vol), storageClassName(want); from != to {
+				reasons = append(reasons, fmt.Sprintf("storage class of volume %s is changed from %q to %q", want.Name, from, to))
+			}
+			if want.Storage.Cmp(vol.Storage) < 0 {
+				reasons = append(reasons, fmt.Sprintf("volume %s is shrunk from %s to %s",
+					want.Name, vol.Storage.String(), want.Storage.String()))
+			}
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// storageClassName returns the storage class name of a volume, it's empty for the default class
+func storageClassName(vol *v1alpha1.Volume) string {
+	if vol.StorageClassName == nil {
+		return ""
+	}
+	return *vol.StorageClassName
+}
+
+// validateVolumeMounts checks that each mount type is supported by the component and mounted at most once
+func validateVolumeMounts(volumes []v1alpha1.Volume, defaultMountPath func(v1alpha1.VolumeMountType) string) error {
+	mounted := map[v1alpha1.VolumeMountType]string{}
+	for _, vol := range volumes {
+		for _, mount := range vol.Mounts {
+			if defaultMountPath(mount.Type) == "" {
+				return fmt.Errorf("mount type %s of volume %s is not supported", mount.Type, vol.Name)
+			}
+			if other, ok := mounted[mount.Type]; ok {
+				return fmt.Errorf("mount type %s is mounted by both volume %s and %s", mount.Type, other, vol.Name)
+			}
+			mounted[mount.Type] = vol.Name
+		}
+	}
+	return nil
+}
+
+// MountPath returns the mount path of a volume mount, defaultMountPath is used if it's not specified
+func MountPath(mount *v1alpha1.VolumeMount, defaultMountPath func(v1alpha1.VolumeMountType) string) string {
+	if mount.MountPath != "" {
+		return mount.MountPath
+	}
+	return defaultMountPath(mount.Type)
+}
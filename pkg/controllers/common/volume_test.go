@@ -0,0 +1,190 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestVolumeResize(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Now()
+	pvc := func(capacity string, condType corev1.PersistentVolumeClaimConditionType, since time.Time) *corev1.PersistentVolumeClaim {
+		pvc := &corev1.PersistentVolumeClaim{
+			Status: corev1.PersistentVolumeClaimStatus{
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+			},
+		}
+		if condType != "" {
+			pvc.Status.Conditions = []corev1.PersistentVolumeClaimCondition{{
+				Type:               condType,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(since),
+			}}
+		}
+		return pvc
+	}
+	oldPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))}}
+	newPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now)}}
+
+	tcs := []struct {
+		caseName    string
+		pvcs        []*corev1.PersistentVolumeClaim
+		pod         *corev1.Pod
+		wantReason  string
+		wantRestart bool
+	}{{
+		caseName:   "resized",
+		pvcs:       []*corev1.PersistentVolumeClaim{pvc("10Gi", "", now)},
+		pod:        oldPod,
+		wantReason: v1alpha1.ReasonVolumesResized,
+	}, {
+		caseName:   "capacity is not updated",
+		pvcs:       []*corev1.PersistentVolumeClaim{pvc("5Gi", "", now)},
+		pod:        oldPod,
+		wantReason: v1alpha1.ReasonVolumeResizing,
+	}, {
+		caseName:   "controller is resizing",
+		pvcs:       []*corev1.PersistentVolumeClaim{pvc("5Gi", corev1.PersistentVolumeClaimResizing, now)},
+		pod:        oldPod,
+		wantReason: v1alpha1.ReasonVolumeResizing,
+	}, {
+		caseName:   "wait for online file system resize",
+		pvcs:       []*corev1.PersistentVolumeClaim{pvc("10Gi", corev1.PersistentVolumeClaimFileSystemResizePending, now.Add(-time.Minute))},
+		pod:        oldPod,
+		wantReason: v1alpha1.ReasonFileSystemResizePending,
+	}, {
+		caseName: "restart pod for file system resize",
+		pvcs: []*corev1.PersistentVolumeClaim{
+			pvc("5Gi", corev1.PersistentVolumeClaimResizing, now),
+			pvc("10Gi", corev1.PersistentVolumeClaimFileSystemResizePending, now.Add(-10*time.Minute)),
+		},
+		pod:         oldPod,
+		wantReason:  v1alpha1.ReasonFileSystemResizePending,
+		wantRestart: true,
+	}, {
+		caseName:   "pod is already restarted",
+		pvcs:       []*corev1.PersistentVolumeClaim{pvc("10Gi", corev1.PersistentVolumeClaimFileSystemResizePending, now.Add(-10*time.Minute))},
+		pod:        newPod,
+		wantReason: v1alpha1.ReasonFileSystemResizePending,
+	}}
+
+	for _, tc := range tcs {
+		resize := &volumeResize{}
+		for _, p := range tc.pvcs {
+			resize.observe(p, resource.MustParse("10Gi"))
+		}
+		g.Expect(resize.condition(1).Reason).To(Equal(tc.wantReason), tc.caseName)
+		g.Expect(resize.needRestartPod(tc.pod, now)).To(Equal(tc.wantRestart), tc.caseName)
+	}
+}
+
+func TestVolumeReplaceReason(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ssd, nvme := "ssd", "local-nvme"
+	vol := func(storage string, class *string) []v1alpha1.Volume {
+		return []v1alpha1.Volume{{Name: "data", Storage: resource.MustParse(storage), StorageClassName: class}}
+	}
+
+	tcs := []struct {
+		caseName    string
+		current     []v1alpha1.Volume
+		desired     []v1alpha1.Volume
+		wantReplace bool
+	}{{
+		caseName: "unchanged",
+		current:  vol("10Gi", &ssd),
+		desired:  vol("10Gi", &ssd),
+	}, {
+		caseName: "expanded",
+		current:  vol("10Gi", &ssd),
+		desired:  vol("20Gi", &ssd),
+	}, {
+		caseName:    "shrunk",
+		current:     vol("20Gi", &ssd),
+		desired:     vol("10Gi", &ssd),
+		wantReplace: true,
+	}, {
+		caseName:    "storage class is changed",
+		current:     vol("10Gi", &ssd),
+		desired:     vol("10Gi", &nvme),
+		wantReplace: true,
+	}, {
+		caseName:    "default storage class is changed",
+		current:     vol("10Gi", nil),
+		desired:     vol("10Gi", &nvme),
+		wantReplace: true,
+	}, {
+		caseName: "volume is added",
+		current:  nil,
+		desired:  vol("10Gi", &nvme),
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(volumeReplaceReason(tc.current, tc.desired) != "").To(Equal(tc.wantReplace), tc.caseName)
+	}
+}
+
+func TestValidateVolumeMounts(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	defaultMountPath := func(t v1alpha1.VolumeMountType) string {
+		if t == v1alpha1.VolumeMountTypeTiKVData || t == v1alpha1.VolumeMountTypeTiKVWAL {
+			return "/var/lib/" + string(t)
+		}
+		return ""
+	}
+	vol := func(name string, types ...v1alpha1.VolumeMountType) v1alpha1.Volume {
+		v := v1alpha1.Volume{Name: name}
+		for _, t := range types {
+			v.Mounts = append(v.Mounts, v1alpha1.VolumeMount{Type: t})
+		}
+		return v
+	}
+
+	tcs := []struct {
+		caseName string
+		volumes  []v1alpha1.Volume
+		wantErr  bool
+	}{{
+		caseName: "separate volumes",
+		volumes:  []v1alpha1.Volume{vol("data", v1alpha1.VolumeMountTypeTiKVData), vol("wal", v1alpha1.VolumeMountTypeTiKVWAL)},
+	}, {
+		caseName: "one volume with multiple types",
+		volumes:  []v1alpha1.Volume{vol("data", v1alpha1.VolumeMountTypeTiKVData, v1alpha1.VolumeMountTypeTiKVWAL)},
+	}, {
+		caseName: "type is mounted twice",
+		volumes:  []v1alpha1.Volume{vol("data", v1alpha1.VolumeMountTypeTiKVData), vol("data2", v1alpha1.VolumeMountTypeTiKVData)},
+		wantErr:  true,
+	}, {
+		caseName: "type is not supported",
+		volumes:  []v1alpha1.Volume{vol("titan", v1alpha1.VolumeMountTypeTiKVTitan)},
+		wantErr:  true,
+	}}
+
+	for _, tc := range tcs {
+		err := validateVolumeMounts(tc.volumes, defaultMountPath)
+		g.Expect(err != nil).To(Equal(tc.wantErr), tc.caseName)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedrain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/metrics"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+const (
+	// IndexPodNodeName is the field index of pods by the node name
+	IndexPodNodeName = "spec.nodeName"
+
+	// evictionCheckInterval is the interval to check leader count of a store whose leaders are being evicted
+	evictionCheckInterval = 10 * time.Second
+)
+
+// Reconciler evicts leaders of TiKV stores whose pods are on draining nodes,
+// i.e. nodes which are cordoned or tainted with NoExecute, whose groups are suspended,
+// or whose outdated pods are allowed to be restarted by their groups.
+// Eviction ends when the node is uncordoned, the pod is rescheduled to another node,
+// the group is resumed or the pod is recreated. Nothing is changed while the cluster is paused.
+type Reconciler struct {
+	client.Client
+	Log       logr.Logger
+	Recorder  record.EventRecorder
+	PDControl pdapi.PDControlInterface
+}
+
+// Setup sets up the controller with the Manager.
+func Setup(mgr manager.Manager, pdc pdapi.PDControlInterface) error {
+	r := &Reconciler{
+		Client:    mgr.GetClient(),
+		Log:       mgr.GetLogger().WithName("node-drain"),
+		Recorder:  mgr.GetEventRecorderFor("node-drain"),
+		PDControl: pdc,
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("node-drain").
+		For(&v1alpha1.TiKV{}).
+		Owns(&corev1.Pod{}).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForNode),
+			builder.WithPredicates(drainingChangedPredicate())).
+		Watches(&v1alpha1.Cluster{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForCluster),
+			builder.WithPredicates(pausedChangedPredicate())).
+		Watches(&v1alpha1.TiKVGroup{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForGroup),
+			builder.WithPredicates(suspendChangedPredicate())).
+		WithOptions(controller.Options{}).
+		Complete(r)
+}
+
+// drainingChangedPredicate filters out node updates which don't change whether the node is draining,
+// e.g. heartbeats of node status
+func drainingChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return IsNodeDraining(e.ObjectOld.(*corev1.Node)) != IsNodeDraining(e.ObjectNew.(*corev1.Node))
+		},
+	}
+}
+
+// pausedChangedPredicate filters out cluster updates which don't pause or resume the cluster
+func pausedChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		DeleteFunc: func(event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.(*v1alpha1.Cluster).Spec.Paused != e.ObjectNew.(*v1alpha1.Cluster).Spec.Paused
+		},
+	}
+}
+
+// suspendChangedPredicate filters out group updates which don't suspend or resume the group
+func suspendChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		DeleteFunc: func(event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.(*v1alpha1.TiKVGroup).Spec.Suspend != e.ObjectNew.(*v1alpha1.TiKVGroup).Spec.Suspend
+		},
+	}
+}
+
+func (r *Reconciler) enqueueForCluster(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.enqueueByLabels(ctx, obj.GetNamespace(), client.MatchingLabels{v1alpha1.LabelKeyCluster: obj.GetName()})
+}
+
+func (r *Reconciler) enqueueForGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	tikvGroup := obj.(*v1alpha1.TiKVGroup)
+	return r.enqueueByLabels(ctx, tikvGroup.Namespace, client.MatchingLabels{
+		v1alpha1.LabelKeyCluster: tikvGroup.Spec.Cluster.Name,
+		v1alpha1.LabelKeyGroup:   tikvGroup.Name,
+	})
+}
+
+func (r *Reconciler) enqueueByLabels(ctx context.Context, ns string, labels client.MatchingLabels) []reconcile.Request {
+	var tikvs v1alpha1.TiKVList
+	if err := r.List(ctx, &tikvs, client.InNamespace(ns), labels); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, tikv := range tikvs.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&tikv)})
+	}
+	return requests
+}
+
+func (r *Reconciler) enqueueForNode(ctx context.Context, obj client.Object) []reconcile.Request {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.MatchingFields{IndexPodNodeName: obj.GetName()},
+		client.MatchingLabels{v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV}); err != nil {
+		return []reconcile.Request{}
+	}
+
+	requests := []reconcile.Request{}
+	for _, pod := range pods.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKey{
+				Namespace: pod.Namespace,
+				Name:      pod.Labels[v1alpha1.LabelKeyInstance],
+			},
+		})
+	}
+	return requests
+}
+
+// IsNodeDraining returns whether pods on the node are going to be evicted
+func IsNodeDraining(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoExecute {
+			return true
+		}
+	}
+	return false
+}
+
+// Reconcile begins or ends leader eviction of a TiKV store
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("tikv", req.NamespacedName)
+
+	tikv := &v1alpha1.TiKV{}
+	if err := r.Get(ctx, req.NamespacedName, tikv); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	reason, err := r.evictionReason(ctx, tikv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	evict := reason != "" && tikv.DeletionTimestamp.IsZero()
+
+	cond := meta.FindStatusCondition(tikv.Status.Conditions, v1alpha1.TiKVCondLeadersEvicted)
+	evicting := cond != nil && (cond.Status == metav1.ConditionTrue || cond.Reason == v1alpha1.ReasonEvicting)
+	if !evict && !evicting {
+		return ctrl.Result{}, nil
+	}
+
+	storeID, err := strconv.ParseUint(tikv.Status.ID, 10, 64)
+	if err != nil {
+		// The store is not registered yet, leaders are not on it
+		return ctrl.Result{}, nil
+	}
+	cluster := &v1alpha1.Cluster{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: tikv.Namespace, Name: tikv.Spec.Cluster.Name}, cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// Leaders are neither evicted nor restored while the cluster is paused, it's reconciled again when it's resumed
+	if cluster.Spec.Paused {
+		log.V(1).Info("cluster is paused, skip leader eviction")
+		return ctrl.Result{}, nil
+	}
+	pdc := common.PDClient(r.PDControl, cluster)
+
+	if !evict {
+		if err := pdc.EndEvictLeader(storeID); err != nil {
+			return ctrl.Result{}, r.pdAPIError(tikv, "can't end leader eviction", err)
+		}
+		setLeadersEvictedCondition(tikv, metav1.ConditionFalse, v1alpha1.ReasonNotEvicted, "leader eviction is ended")
+		if err := r.Status().Update(ctx, tikv); err != nil {
+			return ctrl.Result{}, err
+		}
+		metrics.LeaderEvictionStartTime.DeleteLabelValues(tikv.Namespace, tikv.Spec.Cluster.Name,
+			tikv.Labels[v1alpha1.LabelKeyGroup], tikv.Name)
+		log.Info("ended leader eviction", "store", storeID)
+		r.Recorder.Eventf(tikv, corev1.EventTypeNormal, v1alpha1.ReasonLeaderEvictionFinished,
+			"leader eviction of store %d is ended", storeID)
+		return ctrl.Result{}, nil
+	}
+
+	if tikv.Status.State == v1alpha1.StoreStateRemoved {
+		setLeadersEvictedCondition(tikv, metav1.ConditionTrue, v1alpha1.ReasonStoreIsRemoved, "store is removed")
+		return ctrl.Result{}, r.Status().Update(ctx, tikv)
+	}
+
+	// The eviction is recorded before the scheduler is added, so a scheduler in PD
+	// always has a TiKV pointing to it and is removed when the eviction is ended
+	if !evicting {
+		setLeadersEvictedCondition(tikv, metav1.ConditionFalse, v1alpha1.ReasonEvicting,
+			fmt.Sprintf("%s, leaders are being evicted", reason))
+		if err := r.Status().Update(ctx, tikv); err != nil {
+			return ctrl.Result{}, err
+		}
+		metrics.LeaderEvictionStartTime.WithLabelValues(tikv.Namespace, tikv.Spec.Cluster.Name,
+			tikv.Labels[v1alpha1.LabelKeyGroup], tikv.Name).SetToCurrentTime()
+		log.Info("began leader eviction", "store", storeID, "reason", reason)
+		r.Recorder.Eventf(tikv, corev1.EventTypeNormal, v1alpha1.ReasonLeaderEvictionStarted,
+			"%s, leaders of store %d are being evicted", reason, storeID)
+	}
+
+	// Begin is idempotent, it's called on every check in case the scheduler is removed by others
+	if err := pdc.BeginEvictLeader(storeID); err != nil {
+		return ctrl.Result{}, r.pdAPIError(tikv, "can't begin leader eviction", err)
+	}
+
+	store, err := pdc.GetStore(storeID)
+	if err != nil {
+		return ctrl.Result{}, r.pdAPIError(tikv, "can't get store", err)
+	}
+	if store.Status == nil || store.Status.LeaderCount > 0 {
+		msg := fmt.Sprintf("%s, leaders are being evicted", reason)
+		if store.Status != nil {
+			msg = fmt.Sprintf("%s, %d leaders are left", reason, store.Status.LeaderCount)
+		}
+		setLeadersEvictedCondition(tikv, metav1.ConditionFalse, v1alpha1.ReasonEvicting, msg)
+		if err := r.Status().Update(ctx, tikv); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: evictionCheckInterval}, nil
+	}
+
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		r.Recorder.Eventf(tikv, corev1.EventTypeNormal, v1alpha1.ReasonLeaderEvictionFinished,
+			"all leaders of store %d are evicted", storeID)
+	}
+	setLeadersEvictedCondition(tikv, metav1.ConditionTrue, v1alpha1.ReasonEvicted,
+		fmt.Sprintf("%s, all leaders are evicted", reason))
+	return ctrl.Result{}, r.Status().Update(ctx, tikv)
+}
+
+// evictionReason returns why leaders of a TiKV should be evicted, it's empty if they shouldn't.
+// The group is checked first because the pod of a suspended TiKV is deleted once its leaders are evicted.
+func (r *Reconciler) evictionReason(ctx context.Context, tikv *v1alpha1.TiKV) (string, error) {
+	tikvGroup := &v1alpha1.TiKVGroup{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: tikv.Namespace, Name: tikv.Labels[v1alpha1.LabelKeyGroup]}, tikvGroup)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if err == nil && tikvGroup.Spec.Suspend {
+		return fmt.Sprintf("group %s is suspended", tikvGroup.Name), nil
+	}
+	if common.IsPodRestartAllowed(tikv) && !common.IsPodSynced(tikv) {
+		return "pod is being restarted", nil
+	}
+
+	node, err := r.nodeOf(ctx, tikv)
+	if err != nil {
+		return "", err
+	}
+	if node != nil && IsNodeDraining(node) {
+		return fmt.Sprintf("node %s is draining", node.Name), nil
+	}
+	return "", nil
+}
+
+// nodeOf returns the node of the pod of a TiKV, it's nil if the pod is not scheduled
+func (r *Reconciler) nodeOf(ctx context.Context, tikv *v1alpha1.TiKV) (*corev1.Node, error) {
+	pod := &corev1.Pod{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(tikv), pod); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	node := &corev1.Node{}
+	if err := r.Get(ctx, client.ObjectKey{Name: pod.Spec.NodeName}, node); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return node, nil
+}
+
+func (r *Reconciler) pdAPIError(tikv *v1alpha1.TiKV, msg string, err error) error {
+	r.Recorder.Eventf(tikv, corev1.EventTypeWarning, v1alpha1.ReasonPDAPIError, "%s: %v", msg, err)
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func setLeadersEvictedCondition(tikv *v1alpha1.TiKV, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&tikv.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.TiKVCondLeadersEvicted,
+		Status:             status,
+		ObservedGeneration: tikv.Generation,
+		Reason:             reason,
+		Message:            msg,
+	})
+}
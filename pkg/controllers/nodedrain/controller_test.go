@@ -0,0 +1,280 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedrain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+// fakePDControl returns the same fake client for all clusters
+type fakePDControl struct {
+	pdc pdapi.PDClient
+}
+
+func (c *fakePDControl) GetPDClient(pdapi.Namespace, string, bool, ...pdapi.Option) pdapi.PDClient {
+	return c.pdc
+}
+
+func (c *fakePDControl) GetPDEtcdClient(pdapi.Namespace, string, bool, ...pdapi.Option) (pdapi.PDEtcdClient, error) {
+	return nil, nil
+}
+
+func TestIsNodeDraining(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		spec     corev1.NodeSpec
+		want     bool
+	}{{
+		caseName: "schedulable",
+	}, {
+		caseName: "cordoned",
+		spec:     corev1.NodeSpec{Unschedulable: true},
+		want:     true,
+	}, {
+		caseName: "NoSchedule taint",
+		spec:     corev1.NodeSpec{Taints: []corev1.Taint{{Key: "k", Effect: corev1.TaintEffectNoSchedule}}},
+	}, {
+		caseName: "NoExecute taint",
+		spec:     corev1.NodeSpec{Taints: []corev1.Taint{{Key: "k", Effect: corev1.TaintEffectNoExecute}}},
+		want:     true,
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(IsNodeDraining(&corev1.Node{Spec: tc.spec})).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	evicting := metav1.Condition{Type: v1alpha1.TiKVCondLeadersEvicted, Status: metav1.ConditionFalse,
+		Reason: v1alpha1.ReasonEvicting}
+	evicted := metav1.Condition{Type: v1alpha1.TiKVCondLeadersEvicted, Status: metav1.ConditionTrue,
+		Reason: v1alpha1.ReasonEvicted}
+	notEvicted := metav1.Condition{Type: v1alpha1.TiKVCondLeadersEvicted, Status: metav1.ConditionFalse,
+		Reason: v1alpha1.ReasonNotEvicted}
+
+	tcs := []struct {
+		caseName    string
+		draining    bool
+		noPod       bool
+		suspended   bool
+		restart     bool
+		podSynced   bool
+		paused      bool
+		id          string
+		state       string
+		cond        *metav1.Condition
+		leaderCount int
+
+		wantBegin   bool
+		wantEnd     bool
+		wantReason  string
+		wantRequeue bool
+	}{{
+		caseName: "node is not draining",
+		id:       "1",
+	}, {
+		caseName:    "node begins draining",
+		draining:    true,
+		id:          "1",
+		leaderCount: 5,
+		wantBegin:   true,
+		wantReason:  v1alpha1.ReasonEvicting,
+		wantRequeue: true,
+	}, {
+		caseName:   "all leaders are evicted",
+		draining:   true,
+		id:         "1",
+		cond:       &evicting,
+		wantBegin:  true,
+		wantReason: v1alpha1.ReasonEvicted,
+	}, {
+		caseName:   "node is uncordoned while evicting",
+		id:         "1",
+		cond:       &evicting,
+		wantEnd:    true,
+		wantReason: v1alpha1.ReasonNotEvicted,
+	}, {
+		caseName:   "node is uncordoned after evicted",
+		id:         "1",
+		cond:       &evicted,
+		wantEnd:    true,
+		wantReason: v1alpha1.ReasonNotEvicted,
+	}, {
+		caseName:   "eviction is already ended",
+		id:         "1",
+		cond:       &notEvicted,
+		wantReason: v1alpha1.ReasonNotEvicted,
+	}, {
+		caseName: "store is not registered",
+		draining: true,
+	}, {
+		caseName:   "store is removed",
+		draining:   true,
+		id:         "1",
+		state:      v1alpha1.StoreStateRemoved,
+		wantReason: v1alpha1.ReasonStoreIsRemoved,
+	}, {
+		caseName: "cluster is paused while draining",
+		draining: true,
+		paused:   true,
+		id:       "1",
+	}, {
+		caseName:   "cluster is paused while evicting",
+		paused:     true,
+		id:         "1",
+		cond:       &evicting,
+		wantReason: v1alpha1.ReasonEvicting,
+	}, {
+		caseName:    "group is suspended",
+		suspended:   true,
+		id:          "1",
+		leaderCount: 5,
+		wantBegin:   true,
+		wantReason:  v1alpha1.ReasonEvicting,
+		wantRequeue: true,
+	}, {
+		caseName:   "pod of a suspended group is deleted",
+		suspended:  true,
+		noPod:      true,
+		id:         "1",
+		cond:       &evicted,
+		wantBegin:  true,
+		wantReason: v1alpha1.ReasonEvicted,
+	}, {
+		caseName:   "group is resumed",
+		noPod:      true,
+		id:         "1",
+		cond:       &evicted,
+		wantEnd:    true,
+		wantReason: v1alpha1.ReasonNotEvicted,
+	}, {
+		caseName:    "outdated pod is allowed to be restarted",
+		restart:     true,
+		id:          "1",
+		leaderCount: 5,
+		wantBegin:   true,
+		wantReason:  v1alpha1.ReasonEvicting,
+		wantRequeue: true,
+	}, {
+		caseName:   "pod is recreated",
+		restart:    true,
+		podSynced:  true,
+		id:         "1",
+		cond:       &evicted,
+		wantEnd:    true,
+		wantReason: v1alpha1.ReasonNotEvicted,
+	}}
+
+	for _, tc := range tcs {
+		cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "ns"}}
+		cluster.Spec.Paused = tc.paused
+		tikvGroup := &v1alpha1.TiKVGroup{ObjectMeta: metav1.ObjectMeta{Name: "tikv", Namespace: "ns"}}
+		tikvGroup.Spec.Cluster.Name = "basic"
+		tikvGroup.Spec.Suspend = tc.suspended
+		tikv := &v1alpha1.TiKV{
+			ObjectMeta: metav1.ObjectMeta{Name: "tikv-tikv-0", Namespace: "ns", Labels: map[string]string{
+				v1alpha1.LabelKeyCluster: "basic",
+				v1alpha1.LabelKeyGroup:   "tikv",
+			}},
+			Spec: v1alpha1.TiKVSpec{Cluster: v1alpha1.ClusterReference{Name: "basic"}},
+		}
+		tikv.Status.ID = tc.id
+		tikv.Status.State = tc.state
+		if tc.cond != nil {
+			tikv.Status.Conditions = []metav1.Condition{*tc.cond}
+		}
+		if tc.restart {
+			tikv.Annotations = map[string]string{v1alpha1.AnnoKeyRestartPod: "true"}
+			synced := metav1.Condition{Type: v1alpha1.CondSynced, Status: metav1.ConditionFalse,
+				Reason: v1alpha1.ReasonPodNotUpToDate}
+			if tc.podSynced {
+				synced.Status = metav1.ConditionTrue
+				synced.Reason = v1alpha1.ReasonSynced
+			}
+			tikv.Status.Conditions = append(tikv.Status.Conditions, synced)
+		}
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}
+		node.Spec.Unschedulable = tc.draining
+		objs := []client.Object{cluster, tikvGroup, tikv, node}
+		if !tc.noPod {
+			objs = append(objs, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "tikv-tikv-0", Namespace: "ns"},
+				Spec:       corev1.PodSpec{NodeName: "node-0"},
+			})
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+			WithStatusSubresource(&v1alpha1.TiKV{}).Build()
+
+		began, ended := false, false
+		pdc := pdapi.NewFakePDClient()
+		pdc.AddReaction(pdapi.BeginEvictLeaderActionType, func(action *pdapi.Action) (interface{}, error) {
+			g.Expect(action.ID).To(Equal(uint64(1)), tc.caseName)
+			began = true
+			return nil, nil
+		})
+		pdc.AddReaction(pdapi.EndEvictLeaderActionType, func(action *pdapi.Action) (interface{}, error) {
+			g.Expect(action.ID).To(Equal(uint64(1)), tc.caseName)
+			ended = true
+			return nil, nil
+		})
+		pdc.AddReaction(pdapi.GetStoreActionType, func(*pdapi.Action) (interface{}, error) {
+			return &pdapi.StoreInfo{Status: &pdapi.StoreStatus{LeaderCount: tc.leaderCount}}, nil
+		})
+
+		r := &Reconciler{
+			Client:    c,
+			Log:       logr.Discard(),
+			Recorder:  record.NewFakeRecorder(10),
+			PDControl: &fakePDControl{pdc: pdc},
+		}
+		res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tikv)})
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(res.RequeueAfter > 0).To(Equal(tc.wantRequeue), tc.caseName)
+		g.Expect(began).To(Equal(tc.wantBegin), tc.caseName)
+		g.Expect(ended).To(Equal(tc.wantEnd), tc.caseName)
+
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(tikv), tikv)).To(Succeed(), tc.caseName)
+		cond := meta.FindStatusCondition(tikv.Status.Conditions, v1alpha1.TiKVCondLeadersEvicted)
+		if tc.wantReason == "" {
+			g.Expect(cond).To(BeNil(), tc.caseName)
+			continue
+		}
+		g.Expect(cond).NotTo(BeNil(), tc.caseName)
+		g.Expect(cond.Reason).To(Equal(tc.wantReason), tc.caseName)
+	}
+}
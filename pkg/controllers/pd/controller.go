@@ -16,361 +16,290 @@ package pd
 import (
 	"context"
 	"fmt"
+	"path"
+	"strconv"
 
-	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/config"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
 )
 
-// PDReconciler reconciles a PD instance by managing its Pod, ConfigMap, and PVCs
-type PDReconciler struct {
-	client.Client
-	Scheme *runtime.Scheme
-	Log    logr.Logger
+// Setup sets up the controller with the Manager.
+func Setup(mgr manager.Manager, pdc pdapi.PDControlInterface) error {
+	return common.SetupInstanceController[*v1alpha1.PDGroup, *v1alpha1.PD](mgr, "pd", pdc, &Adapter{})
 }
 
-// Setup sets up the controller with the Manager.
-func Setup(mgr manager.Manager) error {
-	r := &PDReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    mgr.GetLogger().WithName("pd"),
-	}
+// Adapter provides the PD specific parts of the instance controller
+type Adapter struct {
+	Component
+}
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.PD{}).
-		Owns(&corev1.Pod{}).
-		Owns(&corev1.Service{}).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.PersistentVolumeClaim{}).
-		WithOptions(controller.Options{}).
-		Complete(r)
+var _ common.InstanceAdapter[*v1alpha1.PDGroup, *v1alpha1.PD] = &Adapter{}
+
+// Component provides the types of PD
+type Component struct{}
+
+func (Component) NewGroup() *v1alpha1.PDGroup {
+	return &v1alpha1.PDGroup{}
 }
 
-// Reconcile manages Pod, ConfigMap, and PVCs for a PD instance
-func (r *PDReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("pd", req.NamespacedName)
+func (Component) NewInstance() *v1alpha1.PD {
+	return &v1alpha1.PD{}
+}
 
-	pd := &v1alpha1.PD{}
-	if err := r.Get(ctx, req.NamespacedName, pd); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+func (Component) ListInstances(ctx context.Context, c client.Client, ns string, labels client.MatchingLabels) ([]*v1alpha1.PD, error) {
+	var pdList v1alpha1.PDList
+	if err := c.List(ctx, &pdList, client.InNamespace(ns), labels); err != nil {
+		return nil, err
 	}
-
-	// Check if PD is being deleted
-	if !pd.DeletionTimestamp.IsZero() {
-		// Handle finalizers if needed
-		return ctrl.Result{}, nil
+	pds := make([]*v1alpha1.PD, 0, len(pdList.Items))
+	for i := range pdList.Items {
+		pds = append(pds, &pdList.Items[i])
 	}
+	return pds, nil
+}
 
-	// Ensure Service exists (headless service for PD cluster)
-	if err := r.reconcileService(ctx, pd); err != nil {
-		return ctrl.Result{}, err
-	}
+func (Component) MinReadySeconds() int32 {
+	return v1alpha1.DefaultPDMinReadySeconds
+}
 
-	// Ensure ConfigMap exists
-	if err := r.reconcileConfigMap(ctx, pd); err != nil {
-		return ctrl.Result{}, err
-	}
+const (
+	// HealthPath is the api path of health of PD members
+	HealthPath = "/pd/api/v1/health"
+	// configDir is where the config file and start script are mounted
+	configDir = "/etc/pd"
+)
 
-	// Ensure PVCs exist
-	if err := r.reconcilePVCs(ctx, pd); err != nil {
-		return ctrl.Result{}, err
+// ClientPort returns the client port of PD
+func ClientPort(spec *v1alpha1.PDTemplateSpec) int32 {
+	if spec.Server.Ports.Client != nil {
+		return spec.Server.Ports.Client.Port
 	}
+	return v1alpha1.DefaultPDPortClient
+}
 
-	// Ensure Pod exists
-	if err := r.reconcilePod(ctx, pd); err != nil {
-		return ctrl.Result{}, err
+// PeerPort returns the peer port of PD
+func PeerPort(spec *v1alpha1.PDTemplateSpec) int32 {
+	if spec.Server.Ports.Peer != nil {
+		return spec.Server.Ports.Peer.Port
 	}
+	return v1alpha1.DefaultPDPortPeer
+}
 
-	// Update status from Pod
-	if err := r.updateStatus(ctx, pd); err != nil {
-		return ctrl.Result{}, err
-	}
+// ServiceName returns the name of the headless service of PD in a cluster
+func ServiceName(cluster string) string {
+	return fmt.Sprintf("%s-pd", cluster)
+}
 
-	return ctrl.Result{}, nil
+// subdomain returns the headless service of a PD, it's the service of PD in the cluster by default
+func subdomain(pd *v1alpha1.PD) string {
+	if pd.Spec.Subdomain != "" {
+		return pd.Spec.Subdomain
+	}
+	return ServiceName(pd.Spec.Cluster.Name)
 }
 
-func (r *PDReconciler) reconcileService(ctx context.Context, pd *v1alpha1.PD) error {
-	// Service name is based on cluster name, not subdomain
-	svcName := fmt.Sprintf("%s-pd", pd.Spec.Cluster.Name)
-	svc := &corev1.Service{
+func (*Adapter) Service(pd *v1alpha1.PD) *corev1.Service {
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      svcName,
+			Name:      subdomain(pd),
 			Namespace: pd.Namespace,
+			Labels: map[string]string{
+				v1alpha1.LabelKeyManagedBy:       v1alpha1.LabelValManagedByOperator,
+				v1alpha1.LabelKeyCluster:         pd.Spec.Cluster.Name,
+				v1alpha1.LabelKeyComponent:       v1alpha1.LabelValComponentPD,
+				v1alpha1.LabelKeyHeadlessService: "true",
+			},
 		},
-	}
-
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, svc, func() error {
-		svc.Labels = map[string]string{
-			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-			v1alpha1.LabelKeyCluster:   pd.Spec.Cluster.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentPD,
-		}
-		svc.Spec = corev1.ServiceSpec{
+		Spec: corev1.ServiceSpec{
 			ClusterIP: corev1.ClusterIPNone, // Headless service
+			// Members have to resolve each other before they are ready
+			PublishNotReadyAddresses: true,
 			Selector: map[string]string{
 				v1alpha1.LabelKeyCluster:   pd.Spec.Cluster.Name,
 				v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentPD,
 			},
 			Ports: []corev1.ServicePort{
-				{Name: v1alpha1.PDPortNameClient, Port: v1alpha1.DefaultPDPortClient},
-				{Name: v1alpha1.PDPortNamePeer, Port: v1alpha1.DefaultPDPortPeer},
+				{Name: v1alpha1.PDPortNameClient, Port: ClientPort(&pd.Spec.PDTemplateSpec)},
+				{Name: v1alpha1.PDPortNamePeer, Port: PeerPort(&pd.Spec.PDTemplateSpec)},
 			},
-		}
-		// Don't set owner reference for service (shared by all PD instances)
-		return nil
-	})
-
-	if err != nil {
-		return err
-	}
-	if op != ctrl.OperationResultNone {
-		r.Log.Info("Service reconciled", "operation", op, "name", svcName)
-	}
-	return nil
-}
-
-func (r *PDReconciler) reconcileConfigMap(ctx context.Context, pd *v1alpha1.PD) error {
-	cmName := fmt.Sprintf("%s-config", pd.Name)
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      cmName,
-			Namespace: pd.Namespace,
 		},
 	}
+}
 
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, cm, func() error {
-		cm.Labels = map[string]string{
-			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-			v1alpha1.LabelKeyCluster:   pd.Spec.Cluster.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentPD,
-			v1alpha1.LabelKeyInstance:  pd.Name,
-		}
-		cm.Data = map[string]string{
-			"config-file": pd.Spec.Config,
-		}
-		return controllerutil.SetControllerReference(pd, cm, r.Scheme)
-	})
-
-	if err != nil {
-		return err
-	}
-	if op != ctrl.OperationResultNone {
-		r.Log.Info("ConfigMap reconciled", "operation", op, "name", cmName)
-	}
+// ExternalService returns nil, PD is exposed by the external service of its group
+func (*Adapter) ExternalService(*v1alpha1.PD) *corev1.Service {
 	return nil
 }
 
-func (r *PDReconciler) reconcilePVCs(ctx context.Context, pd *v1alpha1.PD) error {
-	for _, vol := range pd.Spec.Volumes {
-		pvc := &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("%s-%s", pd.Name, vol.Name),
-				Namespace: pd.Namespace,
-			},
-		}
-
-		op, err := ctrl.CreateOrUpdate(ctx, r.Client, pvc, func() error {
-			pvc.Labels = map[string]string{
-				v1alpha1.LabelKeyManagedBy:  v1alpha1.LabelValManagedByOperator,
-				v1alpha1.LabelKeyCluster:    pd.Spec.Cluster.Name,
-				v1alpha1.LabelKeyComponent:  v1alpha1.LabelValComponentPD,
-				v1alpha1.LabelKeyInstance:   pd.Name,
-				v1alpha1.LabelKeyVolumeName: vol.Name,
-			}
-			pvc.Spec = corev1.PersistentVolumeClaimSpec{
-				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-				Resources: corev1.ResourceRequirements{
-					Requests: corev1.ResourceList{
-						corev1.ResourceStorage: vol.Storage,
-					},
-				},
-			}
-			if vol.StorageClassName != nil {
-				pvc.Spec.StorageClassName = vol.StorageClassName
-			}
-			return controllerutil.SetControllerReference(pd, pvc, r.Scheme)
-		})
+func (*Adapter) Container(pd *v1alpha1.PD) corev1.Container {
+	image := "pingcap/pd:latest"
+	if pd.Spec.Image != nil {
+		image = *pd.Spec.Image
+	} else if pd.Spec.Version != "" {
+		image = fmt.Sprintf("pingcap/pd:%s", pd.Spec.Version)
+	}
 
-		if err != nil {
-			return err
-		}
-		if op != ctrl.OperationResultNone {
-			r.Log.Info("PVC reconciled", "operation", op, "name", pvc.Name)
-		}
+	clientPort := ClientPort(&pd.Spec.PDTemplateSpec)
+	peerPort := PeerPort(&pd.Spec.PDTemplateSpec)
+	return corev1.Container{
+		Name:  "pd",
+		Image: image,
+		Ports: []corev1.ContainerPort{
+			{Name: v1alpha1.PDPortNameClient, ContainerPort: clientPort},
+			{Name: v1alpha1.PDPortNamePeer, ContainerPort: peerPort},
+		},
+		Command: []string{"/bin/sh", common.StartScriptPath(configDir)},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: v1alpha1.VolumeNameConfig, MountPath: configDir},
+		},
+		ReadinessProbe: common.OverrideProbe(readinessProbe(clientPort), pd.Spec.Probes.Readiness),
+		LivenessProbe:  common.OverrideProbe(livenessProbe(clientPort), pd.Spec.Probes.Liveness),
+		StartupProbe:   common.OverrideProbe(startupProbe(clientPort), pd.Spec.Probes.Startup),
 	}
-	return nil
 }
 
-func (r *PDReconciler) reconcilePod(ctx context.Context, pd *v1alpha1.PD) error {
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      pd.Name,
-			Namespace: pd.Namespace,
+// StartScript starts PD after its own DNS name is resolvable, otherwise it can't advertise its peer url
+func (a *Adapter) StartScript(pc *common.PodContext, pd *v1alpha1.PD) *common.StartScript {
+	clientPort := ClientPort(&pd.Spec.PDTemplateSpec)
+	peerPort := PeerPort(&pd.Spec.PDTemplateSpec)
+	listenHost := common.ListenHost(&pd.Spec.Network)
+	advertiseHost := common.PodHost(pc.Cluster, &pd.Spec.Network, pd.Name, subdomain(pd))
+	script := &common.StartScript{
+		Command: []string{
+			"/pd-server",
+			"--name=" + pd.Name,
+			"--client-urls=http://" + pdapi.JoinHostPort(listenHost, clientPort),
+			"--peer-urls=http://" + pdapi.JoinHostPort(listenHost, peerPort),
+			"--advertise-client-urls=http://" + pdapi.JoinHostPort(advertiseHost, clientPort),
+			"--advertise-peer-urls=http://" + pdapi.JoinHostPort(advertiseHost, peerPort),
+			"--data-dir=" + a.DataDir(pd),
+			"--config=" + path.Join(configDir, v1alpha1.ConfigMapKeyConfig),
 		},
 	}
+	if !pd.Spec.Network.IsHost() {
+		script.WaitDNS = []string{advertiseHost}
+	}
+	return script
+}
 
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, pod, func() error {
-		// Labels
-		pod.Labels = map[string]string{
-			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-			v1alpha1.LabelKeyCluster:   pd.Spec.Cluster.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentPD,
-			v1alpha1.LabelKeyInstance:  pd.Name,
-		}
-
-		// Image
-		image := "pingcap/pd:latest"
-		if pd.Spec.Image != nil {
-			image = *pd.Spec.Image
-		} else if pd.Spec.Version != "" {
-			image = fmt.Sprintf("pingcap/pd:%s", pd.Spec.Version)
-		}
+// readinessProbe checks the health of members in PD's view
+func readinessProbe(port int32) *corev1.Probe {
+	probe := common.HTTPGetProbe(HealthPath, port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = 3
+	return probe
+}
 
-		// Container
-		container := corev1.Container{
-			Name:  "pd",
-			Image: image,
-			Ports: []corev1.ContainerPort{
-				{Name: v1alpha1.PDPortNameClient, ContainerPort: v1alpha1.DefaultPDPortClient},
-				{Name: v1alpha1.PDPortNamePeer, ContainerPort: v1alpha1.DefaultPDPortPeer},
-			},
-			Command: []string{
-				"/pd-server",
-				"--name=$(POD_NAME)",
-				"--client-urls=http://0.0.0.0:2379",
-				"--peer-urls=http://0.0.0.0:2380",
-				"--advertise-client-urls=http://$(POD_NAME).$(PD_SERVICE):2379",
-				"--advertise-peer-urls=http://$(POD_NAME).$(PD_SERVICE):2380",
-				"--data-dir=/var/lib/pd",
-				"--config=/etc/pd/pd.toml",
-			},
-			Env: []corev1.EnvVar{
-				{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{
-					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
-				}},
-				{Name: "PD_SERVICE", Value: svcName},
-			},
-			VolumeMounts: []corev1.VolumeMount{
-				{Name: "config", MountPath: "/etc/pd"},
-			},
-			Resources: corev1.ResourceRequirements{},
-		}
+// livenessProbe only checks the client port, an unhealthy cluster should not restart its members
+func livenessProbe(port int32) *corev1.Probe {
+	probe := common.TCPSocketProbe(port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = 6
+	return probe
+}
 
-		// Resources
-		if pd.Spec.Resources.CPU != nil {
-			container.Resources.Requests[corev1.ResourceCPU] = *pd.Spec.Resources.CPU
-			container.Resources.Limits[corev1.ResourceCPU] = *pd.Spec.Resources.CPU
-		}
-		if pd.Spec.Resources.Memory != nil {
-			container.Resources.Requests[corev1.ResourceMemory] = *pd.Spec.Resources.Memory
-			container.Resources.Limits[corev1.ResourceMemory] = *pd.Spec.Resources.Memory
-		}
+// startupProbe waits up to 5 minutes for the client port to be served
+func startupProbe(port int32) *corev1.Probe {
+	probe := common.TCPSocketProbe(port)
+	probe.PeriodSeconds = 5
+	probe.FailureThreshold = 60
+	return probe
+}
 
-		// Volume mounts for data volumes
-		for _, vol := range pd.Spec.Volumes {
-			for _, mount := range vol.Mounts {
-				mountPath := mount.MountPath
-				if mountPath == "" {
-					if mount.Type == v1alpha1.VolumeMountTypePDData {
-						mountPath = v1alpha1.VolumeMountPDDataDefaultPath
-					}
-				}
-				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-					Name:      vol.Name,
-					MountPath: mountPath,
-					SubPath:   mount.SubPath,
-				})
-			}
-		}
+func (*Adapter) MetricsPort(pd *v1alpha1.PD) int32 {
+	return ClientPort(&pd.Spec.PDTemplateSpec)
+}
 
-		pod.Spec = corev1.PodSpec{
-			Containers: []corev1.Container{container},
-			Volumes: []corev1.Volume{
-				{
-					Name: "config",
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: fmt.Sprintf("%s-config", pd.Name),
-							},
-						},
-					},
-				},
-			},
-		}
+func (*Adapter) DefaultMountPath(t v1alpha1.VolumeMountType) string {
+	if t == v1alpha1.VolumeMountTypePDData {
+		return v1alpha1.VolumeMountPDDataDefaultPath
+	}
+	return ""
+}
 
-		// Add PVC volumes
-		for _, vol := range pd.Spec.Volumes {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: vol.Name,
-				VolumeSource: corev1.VolumeSource{
-					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-						ClaimName: fmt.Sprintf("%s-%s", pd.Name, vol.Name),
-					},
-				},
-			})
+// DataDir returns the data dir of PD, i.e. the mount path of the data volume
+func (a *Adapter) DataDir(pd *v1alpha1.PD) string {
+	for _, vol := range pd.Spec.Volumes {
+		for i := range vol.Mounts {
+			if vol.Mounts[i].Type == v1alpha1.VolumeMountTypePDData {
+				return common.MountPath(&vol.Mounts[i], a.DefaultMountPath)
+			}
 		}
+	}
+	return v1alpha1.VolumeMountPDDataDefaultPath
+}
 
-		// Restart policy
-		pod.Spec.RestartPolicy = corev1.RestartPolicyAlways
-
-		return controllerutil.SetControllerReference(pd, pod, r.Scheme)
-	})
-
+// Config validates the user-specified config of PD and renders it in the canonical form
+func (*Adapter) Config(pd *v1alpha1.PD) (string, error) {
+	cfg, err := config.Parse(pd.Spec.Config)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if op != ctrl.OperationResultNone {
-		r.Log.Info("Pod reconciled", "operation", op, "name", pod.Name)
+	if err := config.PD.Validate(cfg); err != nil {
+		return "", err
 	}
-	return nil
+	return cfg.Render()
 }
 
-func (r *PDReconciler) updateStatus(ctx context.Context, pd *v1alpha1.PD) error {
-	pod := &corev1.Pod{}
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: pd.Namespace,
-		Name:      pd.Name,
-	}, pod); err != nil {
-		// Pod not found, update status accordingly
-		pd.Status.ObservedGeneration = pd.Generation
-		return r.Status().Update(ctx, pd)
-	}
+// SyncPD does nothing, PD members are added and removed by PD itself
+func (*Adapter) SyncPD(pdapi.PDClient, *v1alpha1.PD) (bool, error) {
+	return false, nil
+}
 
-	// Update status based on Pod status
-	pd.Status.CommonStatus.ObservedGeneration = pd.Generation
+// IsStoppable always returns true, PD elects a new leader by itself
+func (*Adapter) IsStoppable(*v1alpha1.PD) (bool, string) {
+	return true, ""
+}
 
-	// Check if Pod is ready
-	if pod.Status.Phase == corev1.PodRunning {
-		ready := false
-		for _, condition := range pod.Status.Conditions {
-			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
-				ready = true
-				break
-			}
-		}
-		if ready {
-			// TODO: Query PD API to get member ID and leader status
-			// For now, set a placeholder
-			if pd.Status.ID == "" {
-				pd.Status.ID = "pending"
-			}
-		}
-	} else {
-		// Pod is not running, clear ID
+// UpdateStatus sets the member id and leader status of a PD from PD's view.
+// A PD is healthy if it's in the health list of members.
+func (*Adapter) UpdateStatus(pdc pdapi.PDClient, recorder record.EventRecorder, _ *common.PodContext,
+	pd *v1alpha1.PD, pod *corev1.Pod) (bool, string, error) {
+	if pod == nil || pod.Status.Phase != corev1.PodRunning {
 		pd.Status.ID = ""
 		pd.Status.IsLeader = false
+		return false, "pod is not running", nil
 	}
 
-	return r.Status().Update(ctx, pd)
+	health, err := pdc.GetHealth()
+	if err != nil {
+		return false, "", err
+	}
+	var member *pdapi.MemberHealth
+	for i := range health.Healths {
+		if health.Healths[i].Name == pd.Name {
+			member = &health.Healths[i]
+			break
+		}
+	}
+	if member == nil {
+		pd.Status.IsLeader = false
+		return false, "member is not found in PD", nil
+	}
+	pd.Status.ID = strconv.FormatUint(member.MemberID, 10)
+
+	leader, err := pdc.GetPDLeader()
+	if err != nil {
+		return false, "", err
+	}
+	wasLeader := pd.Status.IsLeader
+	pd.Status.IsLeader = leader.GetName() == pd.Name
+	if pd.Status.IsLeader && !wasLeader {
+		recorder.Event(pd, corev1.EventTypeNormal, v1alpha1.ReasonPDLeaderTransferred, "member becomes the leader of PD")
+	}
+
+	if !member.Health {
+		return false, "member is not healthy in PD", nil
+	}
+	return true, "", nil
 }
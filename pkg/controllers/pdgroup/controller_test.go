@@ -0,0 +1,58 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdgroup
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+)
+
+func TestMaxUnavailable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName string
+		replicas []int32
+		want     int32
+	}{{
+		caseName: "no group",
+	}, {
+		caseName: "single member",
+		replicas: []int32{1},
+	}, {
+		caseName: "3 members",
+		replicas: []int32{3},
+		want:     1,
+	}, {
+		caseName: "members of all groups",
+		replicas: []int32{3, 2},
+		want:     2,
+	}}
+
+	for _, tc := range tcs {
+		var pdGroups []*v1alpha1.PDGroup
+		for _, replicas := range tc.replicas {
+			pdGroup := &v1alpha1.PDGroup{}
+			pdGroup.Spec.Replicas = &replicas
+			pdGroups = append(pdGroups, pdGroup)
+		}
+
+		mu, err := (&Adapter{}).MaxUnavailable(nil, pdGroups, nil)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(mu).To(Equal(tc.want), tc.caseName)
+	}
+}
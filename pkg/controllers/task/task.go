@@ -0,0 +1,217 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package task implements the task pipeline used by controllers.
+// A reconciliation is split into small named tasks which are executed in order
+// by a Runner, see docs/TASKS_PATTERN.md.
+package task
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Status is the status of a task result
+type Status int
+
+const (
+	// SComplete means the task is done, the next task will be executed
+	SComplete Status = iota
+	// SFail means the task is failed, the runner returns an error
+	SFail
+	// SRetry means the task should be retried after a while
+	SRetry
+	// SWait means the task is waiting for an external event, e.g. a pod is deleted
+	SWait
+	// SBreak means the remaining tasks are skipped without error
+	SBreak
+)
+
+func (s Status) String() string {
+	switch s {
+	case SComplete:
+		return "Complete"
+	case SFail:
+		return "Fail"
+	case SRetry:
+		return "Retry"
+	case SWait:
+		return "Wait"
+	case SBreak:
+		return "Break"
+	}
+	return "Unknown"
+}
+
+// Result is the result of a task
+type Result interface {
+	Status() Status
+	// RequeueAfter is only meaningful when the status is SRetry
+	RequeueAfter() time.Duration
+	Message() string
+	// With sets the message of the result, %w is supported to wrap errors
+	With(format string, args ...any) Result
+}
+
+type result struct {
+	status       Status
+	requeueAfter time.Duration
+	message      string
+}
+
+func (r *result) Status() Status {
+	return r.status
+}
+
+func (r *result) RequeueAfter() time.Duration {
+	return r.requeueAfter
+}
+
+func (r *result) Message() string {
+	return r.message
+}
+
+func (r *result) With(format string, args ...any) Result {
+	r.message = fmt.Errorf(format, args...).Error()
+	return r
+}
+
+// Complete returns a result which lets the runner continue with the next task
+func Complete() Result {
+	return &result{status: SComplete}
+}
+
+// Fail returns a result which stops the runner with an error
+func Fail() Result {
+	return &result{status: SFail}
+}
+
+// Retry returns a result which stops the runner and requeues after d
+func Retry(d time.Duration) Result {
+	return &result{status: SRetry, requeueAfter: d}
+}
+
+// Wait returns a result which stops the runner until the next event
+func Wait() Result {
+	return &result{status: SWait}
+}
+
+// Break returns a result which stops the runner without error
+func Break() Result {
+	return &result{status: SBreak}
+}
+
+// Task is a step of a reconciliation
+type Task interface {
+	Name() string
+	Run(ctx context.Context) Result
+}
+
+type taskFunc struct {
+	name string
+	f    func(ctx context.Context) Result
+}
+
+func (t *taskFunc) Name() string {
+	return t.name
+}
+
+func (t *taskFunc) Run(ctx context.Context) Result {
+	return t.f(ctx)
+}
+
+// NameTaskFunc returns a task with a name
+func NameTaskFunc(name string, f func(ctx context.Context) Result) Task {
+	return &taskFunc{name: name, f: f}
+}
+
+// Condition decides whether some tasks should be executed
+type Condition interface {
+	Satisfy() bool
+}
+
+// CondFunc is a func which implements Condition
+type CondFunc func() bool
+
+func (f CondFunc) Satisfy() bool {
+	return f()
+}
+
+// If executes tasks only if the condition is satisfied
+func If(cond Condition, tasks ...Task) Task {
+	return NameTaskFunc("If", func(ctx context.Context) Result {
+		if !cond.Satisfy() {
+			return Complete().With("condition is not satisfied")
+		}
+		return runTasks(ctx, tasks)
+	})
+}
+
+// IfBreak executes tasks and then breaks the runner if the condition is satisfied
+func IfBreak(cond Condition, tasks ...Task) Task {
+	return NameTaskFunc("IfBreak", func(ctx context.Context) Result {
+		if !cond.Satisfy() {
+			return Complete().With("condition is not satisfied")
+		}
+		res := runTasks(ctx, tasks)
+		if res.Status() != SComplete {
+			return res
+		}
+		return Break().With("condition is satisfied")
+	})
+}
+
+func runTasks(ctx context.Context, tasks []Task) Result {
+	for _, t := range tasks {
+		res := t.Run(ctx)
+		if res.Status() != SComplete {
+			return res
+		}
+	}
+	return Complete()
+}
+
+// Runner executes tasks in order
+type Runner struct {
+	log   logr.Logger
+	tasks []Task
+}
+
+// NewTaskRunner returns a runner of the tasks
+func NewTaskRunner(log logr.Logger, tasks ...Task) *Runner {
+	return &Runner{log: log, tasks: tasks}
+}
+
+// Run executes all tasks until one of them doesn't complete
+func (r *Runner) Run(ctx context.Context) (ctrl.Result, error) {
+	for _, t := range r.tasks {
+		res := t.Run(ctx)
+		r.log.V(1).Info("task is executed", "task", t.Name(), "status", res.Status().String(), "message", res.Message())
+
+		switch res.Status() {
+		case SComplete:
+			continue
+		case SFail:
+			return ctrl.Result{}, fmt.Errorf("task %s failed: %s", t.Name(), res.Message())
+		case SRetry:
+			return ctrl.Result{RequeueAfter: res.RequeueAfter()}, nil
+		case SWait, SBreak:
+			return ctrl.Result{}, nil
+		}
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/config"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+)
+
+// logFileName is the name of the log file in the log volume
+const logFileName = "tikv.log"
+
+// volumeConfigKeys are the config keys set to the mount path of each volume type.
+// The data dir is passed by the --data-dir flag in the start script.
+var volumeConfigKeys = map[v1alpha1.VolumeMountType]string{
+	v1alpha1.VolumeMountTypeTiKVRaftEngine: "raft-engine.dir",
+	v1alpha1.VolumeMountTypeTiKVWAL:        "rocksdb.wal-dir",
+	v1alpha1.VolumeMountTypeTiKVTitan:      "rocksdb.titan.dirname",
+	v1alpha1.VolumeMountTypeTiKVLog:        "log.file.filename",
+}
+
+// configDefault is a config key set by the operator unless it's specified by users
+type configDefault struct {
+	key   string
+	value any
+}
+
+// Config validates the user-specified config and adds defaults derived from the instance,
+// e.g. raft-engine.dir of a raft-engine volume and the block cache capacity from the memory.
+// Keys specified by users are kept. The config is rendered in the canonical form.
+func (a *Adapter) Config(tikv *v1alpha1.TiKV) (string, error) {
+	cfg, err := config.Parse(tikv.Spec.Config)
+	if err != nil {
+		return "", err
+	}
+	if err := config.TiKV.Validate(cfg); err != nil {
+		return "", err
+	}
+	for _, d := range append(a.volumeDefaults(tikv), resourceDefaults(tikv.Spec.Resources)...) {
+		cfg.SetDefault(d.key, d.value)
+	}
+	return cfg.Render()
+}
+
+// volumeDefaults returns dirs of mounted volumes
+func (a *Adapter) volumeDefaults(tikv *v1alpha1.TiKV) []configDefault {
+	defaults := []configDefault{}
+	for _, vol := range tikv.Spec.Volumes {
+		for i := range vol.Mounts {
+			mount := &vol.Mounts[i]
+			key, ok := volumeConfigKeys[mount.Type]
+			if !ok {
+				continue
+			}
+			value := common.MountPath(mount, a.DefaultMountPath)
+			if mount.Type == v1alpha1.VolumeMountTypeTiKVLog {
+				value = path.Join(value, logFileName)
+			}
+			defaults = append(defaults, configDefault{key: key, value: value})
+		}
+	}
+	return defaults
+}
+
+const (
+	// blockCacheRatio is the ratio of the memory limit used by the block cache, it's the default of TiKV
+	blockCacheRatio = 0.45
+	// cpuPerGRPCThread is the number of cores served by each gRPC thread
+	cpuPerGRPCThread = 2
+	// maxGRPCConcurrency is the max number of gRPC threads derived from cpu
+	maxGRPCConcurrency = 8
+)
+
+// resourceDefaults sizes the block cache and the gRPC thread pool by resources of the container.
+// TiKV may see resources of the node instead of the container limits, so it's over-committed otherwise.
+func resourceDefaults(resources v1alpha1.ResourceRequirements) []configDefault {
+	defaults := []configDefault{}
+	if resources.Memory != nil && !resources.Memory.IsZero() {
+		mib := int64(float64(resources.Memory.Value())*blockCacheRatio) >> 20
+		if mib < 1 {
+			mib = 1
+		}
+		defaults = append(defaults, configDefault{
+			key:   "storage.block-cache.capacity",
+			value: fmt.Sprintf("%dMiB", mib),
+		})
+	}
+	if resources.CPU != nil && !resources.CPU.IsZero() {
+		cores := (resources.CPU.MilliValue() + 999) / 1000
+		concurrency := (cores + cpuPerGRPCThread - 1) / cpuPerGRPCThread
+		if concurrency > maxGRPCConcurrency {
+			concurrency = maxGRPCConcurrency
+		}
+		defaults = append(defaults, configDefault{
+			key:   "server.grpc-concurrency",
+			value: concurrency,
+		})
+	}
+	return defaults
+}
+
+// DataDir returns the data dir of TiKV, i.e. the mount path of the data volume
+func (a *Adapter) DataDir(tikv *v1alpha1.TiKV) string {
+	for _, vol := range tikv.Spec.Volumes {
+		for i := range vol.Mounts {
+			if vol.Mounts[i].Type == v1alpha1.VolumeMountTypeTiKVData {
+				return common.MountPath(&vol.Mounts[i], a.DefaultMountPath)
+			}
+		}
+	}
+	return v1alpha1.VolumeMountTiKVDataDefaultPath
+}
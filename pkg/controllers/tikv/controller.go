@@ -16,361 +16,432 @@ package tikv
 import (
 	"context"
 	"fmt"
+	"path"
+	"strconv"
 
-	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/pd"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/metrics"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
 )
 
-// TiKVReconciler reconciles a TiKV instance by managing its Pod, ConfigMap, and PVCs
-type TiKVReconciler struct {
-	client.Client
-	Scheme *runtime.Scheme
-	Log    logr.Logger
+// Setup sets up the controller with the Manager.
+func Setup(mgr manager.Manager, pdc pdapi.PDControlInterface) error {
+	return common.SetupInstanceController[*v1alpha1.TiKVGroup, *v1alpha1.TiKV](mgr, "tikv", pdc, &Adapter{})
 }
 
-// Setup sets up the controller with the Manager.
-func Setup(mgr manager.Manager) error {
-	r := &TiKVReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    mgr.GetLogger().WithName("tikv"),
-	}
-
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.TiKV{}).
-		Owns(&corev1.Pod{}).
-		Owns(&corev1.Service{}).
-		Owns(&corev1.ConfigMap{}).
-		Owns(&corev1.PersistentVolumeClaim{}).
-		WithOptions(controller.Options{}).
-		Complete(r)
+// Adapter provides the TiKV specific parts of the instance controller
+type Adapter struct {
+	Component
 }
 
-// Reconcile manages Pod, ConfigMap, and PVCs for a TiKV instance
-func (r *TiKVReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("tikv", req.NamespacedName)
+var _ common.InstanceAdapter[*v1alpha1.TiKVGroup, *v1alpha1.TiKV] = &Adapter{}
 
-	tikv := &v1alpha1.TiKV{}
-	if err := r.Get(ctx, req.NamespacedName, tikv); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
-	}
+// Component provides the types of TiKV
+type Component struct{}
 
-	// Check if TiKV is being deleted
-	if !tikv.DeletionTimestamp.IsZero() {
-		// Handle finalizers if needed
-		return ctrl.Result{}, nil
-	}
+func (Component) NewGroup() *v1alpha1.TiKVGroup {
+	return &v1alpha1.TiKVGroup{}
+}
 
-	// Ensure Service exists (headless service for TiKV cluster)
-	if err := r.reconcileService(ctx, tikv); err != nil {
-		return ctrl.Result{}, err
-	}
+func (Component) NewInstance() *v1alpha1.TiKV {
+	return &v1alpha1.TiKV{}
+}
 
-	// Ensure ConfigMap exists
-	if err := r.reconcileConfigMap(ctx, tikv); err != nil {
-		return ctrl.Result{}, err
+func (Component) ListInstances(ctx context.Context, c client.Client, ns string, labels client.MatchingLabels) ([]*v1alpha1.TiKV, error) {
+	var tikvList v1alpha1.TiKVList
+	if err := c.List(ctx, &tikvList, client.InNamespace(ns), labels); err != nil {
+		return nil, err
 	}
-
-	// Ensure PVCs exist
-	if err := r.reconcilePVCs(ctx, tikv); err != nil {
-		return ctrl.Result{}, err
+	tikvs := make([]*v1alpha1.TiKV, 0, len(tikvList.Items))
+	for i := range tikvList.Items {
+		tikvs = append(tikvs, &tikvList.Items[i])
 	}
+	return tikvs, nil
+}
 
-	// Ensure Pod exists
-	if err := r.reconcilePod(ctx, tikv); err != nil {
-		return ctrl.Result{}, err
-	}
+func (Component) MinReadySeconds() int32 {
+	return v1alpha1.DefaultTiKVMinReadySeconds
+}
+
+const (
+	// statusPath is the api path of the status of TiKV
+	statusPath = "/status"
+	// configDir is where the config file and start script are mounted
+	configDir = "/etc/tikv"
+)
 
-	// Update status from Pod
-	if err := r.updateStatus(ctx, tikv); err != nil {
-		return ctrl.Result{}, err
+// ServiceName returns the name of the headless service of TiKV in a cluster
+func ServiceName(cluster string) string {
+	return fmt.Sprintf("%s-tikv", cluster)
+}
+
+// ClientPort returns the client port of TiKV
+func ClientPort(spec *v1alpha1.TiKVTemplateSpec) int32 {
+	if spec.Server.Ports.Client != nil {
+		return spec.Server.Ports.Client.Port
 	}
+	return v1alpha1.DefaultTiKVPortClient
+}
 
-	return ctrl.Result{}, nil
+// StatusPort returns the status port of TiKV
+func StatusPort(spec *v1alpha1.TiKVTemplateSpec) int32 {
+	if spec.Server.Ports.Status != nil {
+		return spec.Server.Ports.Status.Port
+	}
+	return v1alpha1.DefaultTiKVPortStatus
 }
 
-func (r *TiKVReconciler) reconcileService(ctx context.Context, tikv *v1alpha1.TiKV) error {
-	svcName := fmt.Sprintf("%s-tikv", tikv.Spec.Cluster.Name)
-	svc := &corev1.Service{
+func (*Adapter) Service(tikv *v1alpha1.TiKV) *corev1.Service {
+	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      svcName,
+			Name:      ServiceName(tikv.Spec.Cluster.Name),
 			Namespace: tikv.Namespace,
+			Labels: map[string]string{
+				v1alpha1.LabelKeyManagedBy:       v1alpha1.LabelValManagedByOperator,
+				v1alpha1.LabelKeyCluster:         tikv.Spec.Cluster.Name,
+				v1alpha1.LabelKeyComponent:       v1alpha1.LabelValComponentTiKV,
+				v1alpha1.LabelKeyHeadlessService: "true",
+			},
 		},
-	}
-
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, svc, func() error {
-		svc.Labels = map[string]string{
-			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-			v1alpha1.LabelKeyCluster:   tikv.Spec.Cluster.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
-		}
-		svc.Spec = corev1.ServiceSpec{
+		Spec: corev1.ServiceSpec{
 			ClusterIP: corev1.ClusterIPNone, // Headless service
+			// Stores have to be resolvable before they are ready, e.g. to be probed by PD
+			PublishNotReadyAddresses: true,
 			Selector: map[string]string{
 				v1alpha1.LabelKeyCluster:   tikv.Spec.Cluster.Name,
 				v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
 			},
 			Ports: []corev1.ServicePort{
-				{Name: v1alpha1.TiKVPortNameClient, Port: v1alpha1.DefaultTiKVPortClient},
-				{Name: v1alpha1.TiKVPortNameStatus, Port: v1alpha1.DefaultTiKVPortStatus},
+				{Name: v1alpha1.TiKVPortNameClient, Port: ClientPort(&tikv.Spec.TiKVTemplateSpec)},
+				{Name: v1alpha1.TiKVPortNameStatus, Port: StatusPort(&tikv.Spec.TiKVTemplateSpec)},
 			},
-		}
-		// Don't set owner reference for service (shared by all TiKV instances)
+		},
+	}
+}
+
+// ExternalService returns the service of the TiKV which exposes its client port outside of the Kubernetes cluster
+func (*Adapter) ExternalService(tikv *v1alpha1.TiKV) *corev1.Service {
+	if tikv.Spec.Server.Service == nil {
 		return nil
-	})
+	}
+	labels := common.InstanceLabels(tikv.Spec.Cluster.Name, v1alpha1.LabelValComponentTiKV,
+		tikv.Labels[v1alpha1.LabelKeyGroup], tikv.Name)
+	return common.NewExternalService(tikv.Spec.Server.Service, common.InstanceExternalServiceName(tikv), tikv.Namespace,
+		labels, map[string]string{
+			v1alpha1.LabelKeyCluster:   tikv.Spec.Cluster.Name,
+			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
+			v1alpha1.LabelKeyInstance:  tikv.Name,
+		}, []corev1.ServicePort{
+			{Name: v1alpha1.TiKVPortNameClient, Port: ClientPort(&tikv.Spec.TiKVTemplateSpec)},
+		})
+}
 
-	if err != nil {
-		return err
+func (*Adapter) Container(tikv *v1alpha1.TiKV) corev1.Container {
+	image := "pingcap/tikv:v8.5.4"
+	if tikv.Spec.Image != nil {
+		image = *tikv.Spec.Image
+	} else if tikv.Spec.Version != "" {
+		image = fmt.Sprintf("pingcap/tikv:%s", tikv.Spec.Version)
 	}
-	if op != ctrl.OperationResultNone {
-		r.Log.Info("Service reconciled", "operation", op, "name", svcName)
+
+	clientPort := ClientPort(&tikv.Spec.TiKVTemplateSpec)
+	statusPort := StatusPort(&tikv.Spec.TiKVTemplateSpec)
+	return corev1.Container{
+		Name:  "tikv",
+		Image: image,
+		Ports: []corev1.ContainerPort{
+			{Name: v1alpha1.TiKVPortNameClient, ContainerPort: clientPort},
+			{Name: v1alpha1.TiKVPortNameStatus, ContainerPort: statusPort},
+		},
+		Command: []string{"/bin/sh", common.StartScriptPath(configDir)},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: v1alpha1.VolumeNameConfig, MountPath: configDir},
+		},
+		ReadinessProbe: common.OverrideProbe(readinessProbe(statusPort), tikv.Spec.Probes.Readiness),
+		LivenessProbe:  common.OverrideProbe(livenessProbe(statusPort), tikv.Spec.Probes.Liveness),
+		StartupProbe:   common.OverrideProbe(startupProbe(statusPort), tikv.Spec.Probes.Startup),
 	}
-	return nil
 }
 
-func (r *TiKVReconciler) reconcileConfigMap(ctx context.Context, tikv *v1alpha1.TiKV) error {
-	cmName := fmt.Sprintf("%s-config", tikv.Name)
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      cmName,
-			Namespace: tikv.Namespace,
+// StartScript starts TiKV after its own DNS name is resolvable and PD is available,
+// TiKV exits if it can't connect to PD on startup.
+func (a *Adapter) StartScript(pc *common.PodContext, tikv *v1alpha1.TiKV) *common.StartScript {
+	clientPort := ClientPort(&tikv.Spec.TiKVTemplateSpec)
+	statusPort := StatusPort(&tikv.Spec.TiKVTemplateSpec)
+	listenHost := common.ListenHost(&tikv.Spec.Network)
+	advertiseHost := common.PodHost(pc.Cluster, &tikv.Spec.Network, tikv.Name, ServiceName(tikv.Spec.Cluster.Name))
+	pdAddr := pdapi.JoinHostPort(common.ServiceHost(pc.Cluster, pd.ServiceName(tikv.Spec.Cluster.Name)), common.PDClientPort(pc.Cluster))
+	script := &common.StartScript{
+		WaitHTTP: []string{"http://" + pdAddr + pd.HealthPath},
+		Command: []string{
+			"/tikv-server",
+			"--addr=" + pdapi.JoinHostPort(listenHost, clientPort),
+			"--advertise-addr=" + AdvertiseAddr(pc, tikv),
+			"--status-addr=" + pdapi.JoinHostPort(listenHost, statusPort),
+			"--advertise-status-addr=" + pdapi.JoinHostPort(advertiseHost, statusPort),
+			"--pd=" + pdAddr,
+			"--data-dir=" + a.DataDir(tikv),
+			"--config=" + path.Join(configDir, v1alpha1.ConfigMapKeyConfig),
 		},
 	}
+	if !tikv.Spec.Network.IsHost() {
+		script.WaitDNS = []string{advertiseHost}
+	}
+	return script
+}
 
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, cm, func() error {
-		cm.Labels = map[string]string{
-			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-			v1alpha1.LabelKeyCluster:   tikv.Spec.Cluster.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
-			v1alpha1.LabelKeyInstance:  tikv.Name,
-		}
-		cm.Data = map[string]string{
-			"config-file": tikv.Spec.Config,
-		}
-		return controllerutil.SetControllerReference(tikv, cm, r.Scheme)
-	})
+// readinessProbe checks the status api of TiKV
+func readinessProbe(port int32) *corev1.Probe {
+	probe := common.HTTPGetProbe(statusPath, port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = 3
+	return probe
+}
 
-	if err != nil {
-		return err
-	}
-	if op != ctrl.OperationResultNone {
-		r.Log.Info("ConfigMap reconciled", "operation", op, "name", cmName)
+// livenessProbe checks the status port
+func livenessProbe(port int32) *corev1.Probe {
+	probe := common.TCPSocketProbe(port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = 6
+	return probe
+}
+
+// startupProbe waits up to 1 hour for the status api to be served,
+// TiKV may take a long time to recover raft logs after restarts
+func startupProbe(port int32) *corev1.Probe {
+	probe := common.HTTPGetProbe(statusPath, port)
+	probe.PeriodSeconds = 10
+	probe.TimeoutSeconds = 5
+	probe.FailureThreshold = 360
+	return probe
+}
+
+func (*Adapter) MetricsPort(tikv *v1alpha1.TiKV) int32 {
+	return StatusPort(&tikv.Spec.TiKVTemplateSpec)
+}
+
+func (*Adapter) DefaultMountPath(t v1alpha1.VolumeMountType) string {
+	switch t {
+	case v1alpha1.VolumeMountTypeTiKVData:
+		return v1alpha1.VolumeMountTiKVDataDefaultPath
+	case v1alpha1.VolumeMountTypeTiKVRaftEngine:
+		return v1alpha1.VolumeMountTiKVRaftEngineDefaultPath
+	case v1alpha1.VolumeMountTypeTiKVWAL:
+		return v1alpha1.VolumeMountTiKVWALDefaultPath
+	case v1alpha1.VolumeMountTypeTiKVLog:
+		return v1alpha1.VolumeMountTiKVLogDefaultPath
+	case v1alpha1.VolumeMountTypeTiKVTitan:
+		return v1alpha1.VolumeMountTiKVTitanDefaultPath
 	}
-	return nil
+	return ""
 }
 
-func (r *TiKVReconciler) reconcilePVCs(ctx context.Context, tikv *v1alpha1.TiKV) error {
-	for _, vol := range tikv.Spec.Volumes {
-		pvc := &corev1.PersistentVolumeClaim{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("%s-%s", tikv.Name, vol.Name),
-				Namespace: tikv.Namespace,
-			},
-		}
+// UpdateStatus sets the store id and state of a TiKV from PD's view.
+// A TiKV is healthy if its store is Serving and Up.
+// The last known store is kept if the pod is not running.
+func (*Adapter) UpdateStatus(pdc pdapi.PDClient, recorder record.EventRecorder, pc *common.PodContext,
+	tikv *v1alpha1.TiKV, pod *corev1.Pod) (bool, string, error) {
+	defer updateMetrics(tikv)
 
-		op, err := ctrl.CreateOrUpdate(ctx, r.Client, pvc, func() error {
-			pvc.Labels = map[string]string{
-				v1alpha1.LabelKeyManagedBy:  v1alpha1.LabelValManagedByOperator,
-				v1alpha1.LabelKeyCluster:    tikv.Spec.Cluster.Name,
-				v1alpha1.LabelKeyComponent:  v1alpha1.LabelValComponentTiKV,
-				v1alpha1.LabelKeyInstance:   tikv.Name,
-				v1alpha1.LabelKeyVolumeName: vol.Name,
-			}
-			pvc.Spec = corev1.PersistentVolumeClaimSpec{
-				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-				Resources: corev1.ResourceRequirements{
-					Requests: corev1.ResourceList{
-						corev1.ResourceStorage: vol.Storage,
-					},
-				},
-			}
-			if vol.StorageClassName != nil {
-				pvc.Spec.StorageClassName = vol.StorageClassName
-			}
-			return controllerutil.SetControllerReference(tikv, pvc, r.Scheme)
-		})
+	updateOfflineCondition(tikv)
+	if meta.IsStatusConditionTrue(tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType) {
+		return false, "store is removed", nil
+	}
 
+	// An offline store is checked even if the pod is not running, it may have stopped after being removed
+	if tikv.Spec.Offline && tikv.Status.ID != "" {
+		removed, err := checkOfflineStore(pdc, recorder, tikv)
 		if err != nil {
-			return err
+			return false, "", err
 		}
-		if op != ctrl.OperationResultNone {
-			r.Log.Info("PVC reconciled", "operation", op, "name", pvc.Name)
+		if removed {
+			return false, "store is removed", nil
 		}
 	}
-	return nil
-}
 
-func (r *TiKVReconciler) reconcilePod(ctx context.Context, tikv *v1alpha1.TiKV) error {
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      tikv.Name,
-			Namespace: tikv.Namespace,
-		},
+	if pod == nil || pod.Status.Phase != corev1.PodRunning {
+		return false, "pod is not running", nil
 	}
 
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, pod, func() error {
-		// Labels
-		pod.Labels = map[string]string{
-			v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-			v1alpha1.LabelKeyCluster:   tikv.Spec.Cluster.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
-			v1alpha1.LabelKeyInstance:  tikv.Name,
-		}
+	stores, err := pdc.GetStores()
+	if err != nil {
+		return false, "", err
+	}
+	store := findStore(stores, tikv.Status.ID, StoreAddr(pc, tikv, pod))
+	if store == nil {
+		tikv.Status.ID = ""
+		tikv.Status.State = ""
+		return false, "store is not found in PD", nil
+	}
+	tikv.Status.ID = strconv.FormatUint(store.Store.GetId(), 10)
+	tikv.Status.State = store.Store.GetNodeState().String()
 
-		// Image
-		image := "pingcap/tikv:v8.5.4"
-		if tikv.Spec.Image != nil {
-			image = *tikv.Spec.Image
-		} else if tikv.Spec.Version != "" {
-			image = fmt.Sprintf("pingcap/tikv:%s", tikv.Spec.Version)
-		}
+	if tikv.Status.State != v1alpha1.StoreStateServing {
+		return false, fmt.Sprintf("store is %s", tikv.Status.State), nil
+	}
+	if store.Store.StateName != storeStateNameUp {
+		return false, fmt.Sprintf("store is %s", store.Store.StateName), nil
+	}
+	return true, "", nil
+}
 
-		// Container
-		container := corev1.Container{
-			Name:  "tikv",
-			Image: image,
-			Ports: []corev1.ContainerPort{
-				{Name: v1alpha1.TiKVPortNameClient, ContainerPort: v1alpha1.DefaultTiKVPortClient},
-				{Name: v1alpha1.TiKVPortNameStatus, ContainerPort: v1alpha1.DefaultTiKVPortStatus},
-			},
-			Command: []string{
-				"/tikv-server",
-				"--addr=0.0.0.0:20160",
-				"--advertise-addr=$(POD_NAME).$(HEADLESS_SERVICE):20160",
-				"--status-addr=0.0.0.0:20180",
-				"--pd=$(PD_SERVICE):2379",
-				"--data-dir=/var/lib/tikv",
-				"--config=/etc/tikv/tikv.toml",
-			},
-			Env: []corev1.EnvVar{
-				{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{
-					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
-				}},
-				{Name: "HEADLESS_SERVICE", Value: fmt.Sprintf("%s-tikv", tikv.Spec.Cluster.Name)},
-				{Name: "PD_SERVICE", Value: tikv.Spec.Cluster.Name + "-pd"},
-			},
-			VolumeMounts: []corev1.VolumeMount{
-				{Name: "config", MountPath: "/etc/tikv"},
-			},
-			Resources: corev1.ResourceRequirements{},
-		}
+// storeStateNameUp is the state name of stores which are heartbeating normally
+const storeStateNameUp = "Up"
 
-		// Resources
-		if tikv.Spec.Resources.CPU != nil {
-			container.Resources.Requests[corev1.ResourceCPU] = *tikv.Spec.Resources.CPU
-			container.Resources.Limits[corev1.ResourceCPU] = *tikv.Spec.Resources.CPU
-		}
-		if tikv.Spec.Resources.Memory != nil {
-			container.Resources.Requests[corev1.ResourceMemory] = *tikv.Spec.Resources.Memory
-			container.Resources.Limits[corev1.ResourceMemory] = *tikv.Spec.Resources.Memory
-		}
+// AdvertiseAddr returns the client address advertised by a TiKV, it's --advertise-addr of the start script.
+// Clients outside of the Kubernetes cluster connect to the store by the external address if it's set.
+func AdvertiseAddr(pc *common.PodContext, tikv *v1alpha1.TiKV) string {
+	if pc.ExternalAddress != "" {
+		return pc.ExternalAddress
+	}
+	host := common.PodHost(pc.Cluster, &tikv.Spec.Network, tikv.Name, ServiceName(tikv.Spec.Cluster.Name))
+	return pdapi.JoinHostPort(host, ClientPort(&tikv.Spec.TiKVTemplateSpec))
+}
 
-		// Volume mounts for data volumes
-		for _, vol := range tikv.Spec.Volumes {
-			for _, mount := range vol.Mounts {
-				mountPath := mount.MountPath
-				if mountPath == "" {
-					if mount.Type == v1alpha1.VolumeMountTypeTiKVData {
-						mountPath = v1alpha1.VolumeMountTiKVDataDefaultPath
-					}
-				}
-				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-					Name:      vol.Name,
-					MountPath: mountPath,
-					SubPath:   mount.SubPath,
-				})
-			}
-		}
+// StoreAddr returns the address of the store of a TiKV in PD, i.e. the advertised address with ips of the pod expanded.
+// It's empty if the address can't be known yet, e.g. the node of the pod behind a NodePort service is unknown.
+func StoreAddr(pc *common.PodContext, tikv *v1alpha1.TiKV, pod *corev1.Pod) string {
+	addr, ok := common.ExpandPodEnv(AdvertiseAddr(pc, tikv), pod)
+	if !ok {
+		return ""
+	}
+	return addr
+}
 
-		pod.Spec = corev1.PodSpec{
-			Containers: []corev1.Container{container},
-			Volumes: []corev1.Volume{
-				{
-					Name: "config",
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: fmt.Sprintf("%s-config", tikv.Name),
-							},
-						},
-					},
-				},
-			},
+// findStore finds the store of a TiKV by the known store id, or by the address if the id is not known
+// or the store of the id is gone, e.g. the data of the TiKV is wiped and it registers a new store.
+func findStore(stores *pdapi.StoresInfo, id, addr string) *pdapi.StoreInfo {
+	if id, err := strconv.ParseUint(id, 10, 64); err == nil {
+		if store := findStoreByID(stores, id); store != nil {
+			return store
 		}
-
-		// Add PVC volumes
-		for _, vol := range tikv.Spec.Volumes {
-			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
-				Name: vol.Name,
-				VolumeSource: corev1.VolumeSource{
-					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-						ClaimName: fmt.Sprintf("%s-%s", tikv.Name, vol.Name),
-					},
-				},
-			})
+	}
+	if addr == "" {
+		return nil
+	}
+	for _, store := range stores.Stores {
+		if store.Store != nil && store.Store.GetAddress() == addr {
+			return store
 		}
+	}
+	return nil
+}
 
-		// Restart policy
-		pod.Spec.RestartPolicy = corev1.RestartPolicyAlways
-
-		return controllerutil.SetControllerReference(tikv, pod, r.Scheme)
+// updateOfflineCondition records when the store of a TiKV began to be offlined
+func updateOfflineCondition(tikv *v1alpha1.TiKV) {
+	if !tikv.Spec.Offline {
+		meta.RemoveStatusCondition(&tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType)
+		return
+	}
+	if meta.FindStatusCondition(tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType) != nil {
+		return
+	}
+	meta.SetStatusCondition(&tikv.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.StoreOfflinedConditionType,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: tikv.Generation,
+		Reason:             v1alpha1.ReasonOfflineProcessing,
+		Message:            "store is being offlined",
 	})
+}
 
+// SyncPD deletes the store of an offline TiKV in PD, regions are migrated to other stores
+// and the store becomes tombstone. It returns true until the store is removed.
+func (*Adapter) SyncPD(pdc pdapi.PDClient, tikv *v1alpha1.TiKV) (bool, error) {
+	if !tikv.Spec.Offline || tikv.Status.ID == "" ||
+		meta.IsStatusConditionTrue(tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType) {
+		return false, nil
+	}
+	id, err := strconv.ParseUint(tikv.Status.ID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid store id %q: %w", tikv.Status.ID, err)
+	}
+	stores, err := pdc.GetStores()
 	if err != nil {
-		return err
+		return false, err
 	}
-	if op != ctrl.OperationResultNone {
-		r.Log.Info("Pod reconciled", "operation", op, "name", pod.Name)
+	store := findStoreByID(stores, id)
+	if store == nil {
+		// The store is tombstone, the Offlined condition is completed by UpdateStatus
+		return true, nil
 	}
-	return nil
+	if store.Store.GetNodeState().String() == v1alpha1.StoreStateRemoving {
+		return true, nil
+	}
+	return true, pdc.DeleteStore(id)
 }
 
-func (r *TiKVReconciler) updateStatus(ctx context.Context, tikv *v1alpha1.TiKV) error {
-	pod := &corev1.Pod{}
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: tikv.Namespace,
-		Name:      tikv.Name,
-	}, pod); err != nil {
-		// Pod not found, update status accordingly
-		tikv.Status.CommonStatus.ObservedGeneration = tikv.Generation
-		return r.Status().Update(ctx, tikv)
-	}
-
-	// Update status based on Pod status
-	tikv.Status.CommonStatus.ObservedGeneration = tikv.Generation
-
-	// Check if Pod is ready
-	if pod.Status.Phase == corev1.PodRunning {
-		ready := false
-		for _, condition := range pod.Status.Conditions {
-			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
-				ready = true
-				break
-			}
-		}
-		if ready {
-			// TODO: Query PD API to get store ID and state
-			// For now, set a placeholder
-			if tikv.Status.ID == "" {
-				tikv.Status.ID = "pending"
-				tikv.Status.State = v1alpha1.StoreStatePreparing
-			}
+// IsStoppable returns true once leaders of the store are evicted, they are evicted by the node-drain
+// controller while the group is suspended. A TiKV without a store or with a removed store has no leaders,
+// and leaders of a TiKV which is not ready can't be evicted gracefully, so they are stopped right away.
+func (*Adapter) IsStoppable(tikv *v1alpha1.TiKV) (bool, string) {
+	if tikv.Status.ID == "" || tikv.Status.State == v1alpha1.StoreStateRemoved ||
+		!meta.IsStatusConditionTrue(tikv.Status.Conditions, v1alpha1.CondReady) ||
+		meta.IsStatusConditionTrue(tikv.Status.Conditions, v1alpha1.TiKVCondLeadersEvicted) {
+		return true, ""
+	}
+	return false, "leaders are being evicted"
+}
+
+// checkOfflineStore updates the state of the store of an offline TiKV. It returns true and
+// completes the Offlined condition once the store is not alive, i.e. it's tombstone or already removed from PD.
+func checkOfflineStore(pdc pdapi.PDClient, recorder record.EventRecorder, tikv *v1alpha1.TiKV) (bool, error) {
+	id, err := strconv.ParseUint(tikv.Status.ID, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid store id %q: %w", tikv.Status.ID, err)
+	}
+	stores, err := pdc.GetStores()
+	if err != nil {
+		return false, err
+	}
+	if store := findStoreByID(stores, id); store != nil {
+		tikv.Status.State = store.Store.GetNodeState().String()
+		return false, nil
+	}
+
+	// Tombstone stores are not returned as alive stores
+	tikv.Status.State = v1alpha1.StoreStateRemoved
+	meta.SetStatusCondition(&tikv.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.StoreOfflinedConditionType,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: tikv.Generation,
+		Reason:             v1alpha1.ReasonOfflineCompleted,
+		Message:            "store is removed",
+	})
+	recorder.Eventf(tikv, corev1.EventTypeNormal, v1alpha1.ReasonOfflineCompleted, "store %s is removed", tikv.Status.ID)
+	return true, nil
+}
+
+// findStoreByID finds an alive store by its id
+func findStoreByID(stores *pdapi.StoresInfo, id uint64) *pdapi.StoreInfo {
+	for _, store := range stores.Stores {
+		if store.Store != nil && store.Store.GetId() == id {
+			return store
 		}
-	} else {
-		// Pod is not running, clear status
-		tikv.Status.ID = ""
-		tikv.Status.State = ""
 	}
+	return nil
+}
 
-	return r.Status().Update(ctx, tikv)
+func updateMetrics(tikv *v1alpha1.TiKV) {
+	group := tikv.Labels[v1alpha1.LabelKeyGroup]
+	metrics.SetStoreState(tikv.Namespace, tikv.Spec.Cluster.Name, group, tikv.Name, tikv.Status.State)
+
+	cond := meta.FindStatusCondition(tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType)
+	if cond == nil || cond.Status == metav1.ConditionTrue {
+		metrics.StoreOfflineStartTime.DeleteLabelValues(tikv.Namespace, tikv.Spec.Cluster.Name, group, tikv.Name)
+		return
+	}
+	metrics.StoreOfflineStartTime.WithLabelValues(tikv.Namespace, tikv.Spec.Cluster.Name, group, tikv.Name).
+		Set(float64(cond.LastTransitionTime.Unix()))
 }
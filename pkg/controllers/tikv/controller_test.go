@@ -0,0 +1,448 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikv
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+func newTiKV(network v1alpha1.Network) *v1alpha1.TiKV {
+	tikv := &v1alpha1.TiKV{
+		ObjectMeta: metav1.ObjectMeta{Name: "tikv-tikv-0", Namespace: "ns"},
+		Spec:       v1alpha1.TiKVSpec{Cluster: v1alpha1.ClusterReference{Name: "basic"}},
+	}
+	tikv.Spec.Network = network
+	return tikv
+}
+
+func newStore(id uint64, addr string, state metapb.NodeState) *pdapi.StoreInfo {
+	return &pdapi.StoreInfo{
+		Store:  &pdapi.MetaStore{Store: &metapb.Store{Id: id, Address: addr, NodeState: state}, StateName: "Up"},
+		Status: &pdapi.StoreStatus{},
+	}
+}
+
+func TestStoreAddr(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "ns"}}
+	clusterWithDomain := cluster.DeepCopy()
+	clusterWithDomain.Spec.ClusterDomain = "cluster.local"
+	host := v1alpha1.Network{Mode: v1alpha1.NetworkModeHost}
+	hostIPv6 := v1alpha1.Network{Mode: v1alpha1.NetworkModeHost, IPFamily: corev1.IPv6Protocol}
+	scheduled := &corev1.Pod{Status: corev1.PodStatus{HostIP: "10.0.0.2", PodIP: "10.1.0.2"}}
+	scheduledIPv6 := &corev1.Pod{Status: corev1.PodStatus{HostIP: "fd00::2", PodIP: "fd01::2"}}
+
+	tcs := []struct {
+		caseName string
+		pc       *common.PodContext
+		network  v1alpha1.Network
+		pod      *corev1.Pod
+		want     string
+	}{{
+		caseName: "pod network",
+		pc:       &common.PodContext{Cluster: cluster},
+		pod:      scheduled,
+		want:     "tikv-tikv-0.basic-tikv:20160",
+	}, {
+		caseName: "pod network without pod",
+		pc:       &common.PodContext{Cluster: cluster},
+		want:     "tikv-tikv-0.basic-tikv:20160",
+	}, {
+		caseName: "cluster domain",
+		pc:       &common.PodContext{Cluster: clusterWithDomain},
+		pod:      scheduled,
+		want:     "tikv-tikv-0.basic-tikv.ns.svc.cluster.local:20160",
+	}, {
+		caseName: "node port",
+		pc:       &common.PodContext{Cluster: cluster, ExternalAddress: "${HOST_IP}:30160"},
+		pod:      scheduled,
+		want:     "10.0.0.2:30160",
+	}, {
+		caseName: "node port ipv6",
+		pc:       &common.PodContext{Cluster: cluster, ExternalAddress: "[${HOST_IP}]:30160"},
+		pod:      scheduledIPv6,
+		want:     "[fd00::2]:30160",
+	}, {
+		caseName: "node port of an unscheduled pod",
+		pc:       &common.PodContext{Cluster: cluster, ExternalAddress: "${HOST_IP}:30160"},
+		pod:      &corev1.Pod{},
+	}, {
+		caseName: "node port without pod",
+		pc:       &common.PodContext{Cluster: cluster, ExternalAddress: "${HOST_IP}:30160"},
+	}, {
+		caseName: "load balancer",
+		pc:       &common.PodContext{Cluster: cluster, ExternalAddress: "1.2.3.4:20160"},
+		pod:      scheduled,
+		want:     "1.2.3.4:20160",
+	}, {
+		caseName: "host network",
+		pc:       &common.PodContext{Cluster: cluster},
+		network:  host,
+		pod:      scheduled,
+		want:     "10.1.0.2:20160",
+	}, {
+		caseName: "host network ipv6",
+		pc:       &common.PodContext{Cluster: cluster},
+		network:  hostIPv6,
+		pod:      scheduledIPv6,
+		want:     "[fd01::2]:20160",
+	}, {
+		caseName: "host network without pod ip",
+		pc:       &common.PodContext{Cluster: cluster},
+		network:  host,
+		pod:      &corev1.Pod{},
+	}}
+
+	for _, tc := range tcs {
+		g.Expect(StoreAddr(tc.pc, newTiKV(tc.network), tc.pod)).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestFindStore(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	stores := &pdapi.StoresInfo{Stores: []*pdapi.StoreInfo{
+		newStore(1, "tikv-tikv-0.basic-tikv:20160", metapb.NodeState_Serving),
+		newStore(2, "10.0.0.2:30160", metapb.NodeState_Serving),
+		newStore(3, "tikv-tikv-1.basic-tikv:20160", metapb.NodeState_Serving),
+		{Store: nil},
+	}}
+
+	tcs := []struct {
+		caseName string
+		id       string
+		addr     string
+		wantID   uint64
+	}{{
+		caseName: "by id",
+		id:       "2",
+		addr:     "tikv-tikv-0.basic-tikv:20160",
+		wantID:   2,
+	}, {
+		caseName: "by address",
+		addr:     "10.0.0.2:30160",
+		wantID:   2,
+	}, {
+		caseName: "store of the id is gone",
+		id:       "4",
+		addr:     "tikv-tikv-1.basic-tikv:20160",
+		wantID:   3,
+	}, {
+		caseName: "address is a prefix of another address",
+		addr:     "tikv-tikv-1.basic-tikv:2016",
+	}, {
+		caseName: "address with another cluster domain",
+		addr:     "tikv-tikv-0.basic-tikv.ns.svc.cluster.local:20160",
+	}, {
+		caseName: "unknown address",
+		id:       "4",
+	}, {
+		caseName: "invalid id",
+		id:       "x",
+		addr:     "tikv-tikv-0.basic-tikv:20160",
+		wantID:   1,
+	}}
+
+	for _, tc := range tcs {
+		store := findStore(stores, tc.id, tc.addr)
+		if tc.wantID == 0 {
+			g.Expect(store).To(BeNil(), tc.caseName)
+			continue
+		}
+		g.Expect(store).NotTo(BeNil(), tc.caseName)
+		g.Expect(store.Store.GetId()).To(Equal(tc.wantID), tc.caseName)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cluster := &v1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "basic", Namespace: "ns"}}
+	running := &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+	addr := "tikv-tikv-0.basic-tikv:20160"
+	disconnected := newStore(1, addr, metapb.NodeState_Serving)
+	disconnected.Store.StateName = "Disconnected"
+
+	tcs := []struct {
+		caseName    string
+		id          string
+		pod         *corev1.Pod
+		store       *pdapi.StoreInfo
+		wantHealthy bool
+		wantID      string
+		wantState   string
+	}{{
+		caseName: "pod is not created",
+		id:       "1",
+		store:    newStore(1, addr, metapb.NodeState_Serving),
+		wantID:   "1",
+	}, {
+		caseName: "pod is pending",
+		id:       "1",
+		pod:      &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}},
+		store:    newStore(1, addr, metapb.NodeState_Serving),
+		wantID:   "1",
+	}, {
+		caseName:    "store is serving and up",
+		pod:         running,
+		store:       newStore(1, addr, metapb.NodeState_Serving),
+		wantHealthy: true,
+		wantID:      "1",
+		wantState:   v1alpha1.StoreStateServing,
+	}, {
+		caseName:  "store is preparing",
+		pod:       running,
+		store:     newStore(1, addr, metapb.NodeState_Preparing),
+		wantID:    "1",
+		wantState: v1alpha1.StoreStatePreparing,
+	}, {
+		caseName:  "store is disconnected",
+		pod:       running,
+		store:     disconnected,
+		wantID:    "1",
+		wantState: v1alpha1.StoreStateServing,
+	}, {
+		caseName:    "store is matched by the known id",
+		id:          "1",
+		pod:         running,
+		store:       newStore(1, "10.0.0.2:30160", metapb.NodeState_Serving),
+		wantHealthy: true,
+		wantID:      "1",
+		wantState:   v1alpha1.StoreStateServing,
+	}, {
+		caseName: "store is not registered",
+		id:       "1",
+		pod:      running,
+	}}
+
+	for _, tc := range tcs {
+		tikv := newTiKV(v1alpha1.Network{})
+		tikv.Status.ID = tc.id
+
+		pdc := pdapi.NewFakePDClient()
+		pdc.AddReaction(pdapi.GetStoresActionType, func(*pdapi.Action) (interface{}, error) {
+			stores := &pdapi.StoresInfo{}
+			if tc.store != nil {
+				stores.Stores = append(stores.Stores, tc.store)
+			}
+			return stores, nil
+		})
+
+		healthy, _, err := (&Adapter{}).UpdateStatus(pdc, record.NewFakeRecorder(10),
+			&common.PodContext{Cluster: cluster}, tikv, tc.pod)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(healthy).To(Equal(tc.wantHealthy), tc.caseName)
+		g.Expect(tikv.Status.ID).To(Equal(tc.wantID), tc.caseName)
+		g.Expect(tikv.Status.State).To(Equal(tc.wantState), tc.caseName)
+	}
+}
+
+func TestSyncPD(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	offlined := []metav1.Condition{{Type: v1alpha1.StoreOfflinedConditionType, Status: metav1.ConditionTrue}}
+	tcs := []struct {
+		caseName     string
+		offline      bool
+		id           string
+		conds        []metav1.Condition
+		store        *pdapi.StoreInfo
+		getErr       error
+		wantProgress bool
+		wantErr      bool
+		wantDeleted  bool
+	}{{
+		caseName: "not offline",
+		id:       "1",
+		store:    newStore(1, "", metapb.NodeState_Serving),
+	}, {
+		caseName: "store is not registered",
+		offline:  true,
+	}, {
+		caseName: "offline is completed",
+		offline:  true,
+		id:       "1",
+		conds:    offlined,
+	}, {
+		caseName:     "serving store",
+		offline:      true,
+		id:           "1",
+		store:        newStore(1, "", metapb.NodeState_Serving),
+		wantProgress: true,
+		wantDeleted:  true,
+	}, {
+		caseName:     "removing store",
+		offline:      true,
+		id:           "1",
+		store:        newStore(1, "", metapb.NodeState_Removing),
+		wantProgress: true,
+	}, {
+		caseName:     "store is gone",
+		offline:      true,
+		id:           "1",
+		wantProgress: true,
+	}, {
+		caseName: "invalid id",
+		offline:  true,
+		id:       "x",
+		wantErr:  true,
+	}, {
+		caseName: "pd is unavailable",
+		offline:  true,
+		id:       "1",
+		getErr:   fmt.Errorf("connection refused"),
+		wantErr:  true,
+	}}
+
+	for _, tc := range tcs {
+		tikv := newTiKV(v1alpha1.Network{})
+		tikv.Spec.Offline = tc.offline
+		tikv.Status.ID = tc.id
+		tikv.Status.Conditions = tc.conds
+
+		deleted := false
+		pdc := pdapi.NewFakePDClient()
+		pdc.AddReaction(pdapi.GetStoresActionType, func(*pdapi.Action) (interface{}, error) {
+			stores := &pdapi.StoresInfo{}
+			if tc.store != nil {
+				stores.Stores = append(stores.Stores, tc.store)
+			}
+			return stores, tc.getErr
+		})
+		pdc.AddReaction(pdapi.DeleteStoreActionType, func(action *pdapi.Action) (interface{}, error) {
+			g.Expect(action.ID).To(Equal(uint64(1)), tc.caseName)
+			deleted = true
+			return nil, nil
+		})
+
+		progress, err := (&Adapter{}).SyncPD(pdc, tikv)
+		g.Expect(progress).To(Equal(tc.wantProgress), tc.caseName)
+		g.Expect(err != nil).To(Equal(tc.wantErr), tc.caseName)
+		g.Expect(deleted).To(Equal(tc.wantDeleted), tc.caseName)
+	}
+}
+
+func TestCheckOfflineStore(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		caseName    string
+		store       *pdapi.StoreInfo
+		wantRemoved bool
+		wantState   string
+	}{{
+		caseName:  "removing store",
+		store:     newStore(1, "", metapb.NodeState_Removing),
+		wantState: v1alpha1.StoreStateRemoving,
+	}, {
+		caseName:    "store is tombstone",
+		wantRemoved: true,
+		wantState:   v1alpha1.StoreStateRemoved,
+	}}
+
+	for _, tc := range tcs {
+		tikv := newTiKV(v1alpha1.Network{})
+		tikv.Spec.Offline = true
+		tikv.Status.ID = "1"
+
+		pdc := pdapi.NewFakePDClient()
+		pdc.AddReaction(pdapi.GetStoresActionType, func(*pdapi.Action) (interface{}, error) {
+			stores := &pdapi.StoresInfo{}
+			if tc.store != nil {
+				stores.Stores = append(stores.Stores, tc.store)
+			}
+			return stores, nil
+		})
+		recorder := record.NewFakeRecorder(10)
+
+		removed, err := checkOfflineStore(pdc, recorder, tikv)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(removed).To(Equal(tc.wantRemoved), tc.caseName)
+		g.Expect(tikv.Status.State).To(Equal(tc.wantState), tc.caseName)
+		g.Expect(meta.IsStatusConditionTrue(tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType)).
+			To(Equal(tc.wantRemoved), tc.caseName)
+		g.Expect(recorder.Events).To(HaveLen(len(tikv.Status.Conditions)), tc.caseName)
+	}
+}
+
+func TestIsStoppable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	ready := metav1.Condition{Type: v1alpha1.CondReady, Status: metav1.ConditionTrue}
+	evicted := metav1.Condition{Type: v1alpha1.TiKVCondLeadersEvicted, Status: metav1.ConditionTrue}
+	evicting := metav1.Condition{Type: v1alpha1.TiKVCondLeadersEvicted, Status: metav1.ConditionFalse,
+		Reason: v1alpha1.ReasonEvicting}
+
+	tcs := []struct {
+		caseName string
+		id       string
+		state    string
+		conds    []metav1.Condition
+		want     bool
+	}{{
+		caseName: "store is not registered",
+		conds:    []metav1.Condition{ready},
+		want:     true,
+	}, {
+		caseName: "store is removed",
+		id:       "1",
+		state:    v1alpha1.StoreStateRemoved,
+		conds:    []metav1.Condition{ready},
+		want:     true,
+	}, {
+		caseName: "not ready",
+		id:       "1",
+		state:    v1alpha1.StoreStateServing,
+		want:     true,
+	}, {
+		caseName: "leaders are not evicted",
+		id:       "1",
+		state:    v1alpha1.StoreStateServing,
+		conds:    []metav1.Condition{ready},
+	}, {
+		caseName: "leaders are being evicted",
+		id:       "1",
+		state:    v1alpha1.StoreStateServing,
+		conds:    []metav1.Condition{ready, evicting},
+	}, {
+		caseName: "leaders are evicted",
+		id:       "1",
+		state:    v1alpha1.StoreStateServing,
+		conds:    []metav1.Condition{ready, evicted},
+		want:     true,
+	}}
+
+	for _, tc := range tcs {
+		tikv := newTiKV(v1alpha1.Network{})
+		tikv.Status.ID = tc.id
+		tikv.Status.State = tc.state
+		tikv.Status.Conditions = tc.conds
+
+		ok, _ := (&Adapter{}).IsStoppable(tikv)
+		g.Expect(ok).To(Equal(tc.want), tc.caseName)
+	}
+}
@@ -17,204 +17,170 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/go-logr/logr"
-	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	ctrl "sigs.k8s.io/controller-runtime"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/common"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/controllers/tikv"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
 )
 
-// TiKVGroupReconciler reconciles a TiKVGroup object
-type TiKVGroupReconciler struct {
-	client.Client
-	Scheme *runtime.Scheme
-	Log    logr.Logger
+// Setup sets up the controller with the Manager.
+func Setup(mgr manager.Manager, pdc pdapi.PDControlInterface) error {
+	return common.SetupGroupController[*v1alpha1.TiKVGroup, *v1alpha1.TiKV](mgr, "tikvgroup", pdc, &Adapter{})
 }
 
-// Setup sets up the controller with the Manager.
-func Setup(mgr manager.Manager) error {
-	r := &TiKVGroupReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    mgr.GetLogger().WithName("tikvgroup"),
-	}
-
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.TiKVGroup{}).
-		Owns(&v1alpha1.TiKV{}).
-		Watches(&v1alpha1.Cluster{}, handler.EnqueueRequestsFromMapFunc(r.enqueueForCluster)).
-		WithOptions(controller.Options{}).
-		Complete(r)
+// Adapter provides the TiKV specific parts of the group controller
+type Adapter struct {
+	tikv.Component
 }
 
-func (r *TiKVGroupReconciler) enqueueForCluster(ctx context.Context, obj client.Object) []reconcile.Request {
-	cluster := obj.(*v1alpha1.Cluster)
+var _ common.GroupAdapter[*v1alpha1.TiKVGroup, *v1alpha1.TiKV] = &Adapter{}
+
+func (*Adapter) ListGroups(ctx context.Context, c client.Client, ns, cluster string) ([]*v1alpha1.TiKVGroup, error) {
 	var tikvGroupList v1alpha1.TiKVGroupList
-	if err := r.List(ctx, &tikvGroupList, client.InNamespace(cluster.Namespace),
-		client.MatchingFields{"spec.cluster.name": cluster.Name}); err != nil {
-		return []reconcile.Request{}
+	if err := c.List(ctx, &tikvGroupList, client.InNamespace(ns),
+		client.MatchingFields{"spec.cluster.name": cluster}); err != nil {
+		return nil, err
 	}
-
-	requests := []reconcile.Request{}
-	for _, kvg := range tikvGroupList.Items {
-		requests = append(requests, reconcile.Request{
-			NamespacedName: types.NamespacedName{
-				Name:      kvg.Name,
-				Namespace: kvg.Namespace,
-			},
-		})
+	tikvgs := make([]*v1alpha1.TiKVGroup, 0, len(tikvGroupList.Items))
+	for i := range tikvGroupList.Items {
+		tikvgs = append(tikvgs, &tikvGroupList.Items[i])
 	}
-	return requests
+	return tikvgs, nil
 }
 
-// Reconcile reconciles TiKVGroup by managing TiKV instances
-func (r *TiKVGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("tikvgroup", req.NamespacedName)
+func (*Adapter) Template(tikvGroup *v1alpha1.TiKVGroup) any {
+	return &tikvGroup.Spec.Template.Spec
+}
 
-	tikvGroup := &v1alpha1.TiKVGroup{}
-	if err := r.Get(ctx, req.NamespacedName, tikvGroup); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+func (*Adapter) NewInstanceFromGroup(tikvGroup *v1alpha1.TiKVGroup, name string) *v1alpha1.TiKV {
+	return &v1alpha1.TiKV{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: tikvGroup.Namespace,
+		},
+		Spec: v1alpha1.TiKVSpec{
+			Cluster:          tikvGroup.Spec.Cluster,
+			TiKVTemplateSpec: *tikvGroup.Spec.Template.Spec.DeepCopy(),
+		},
 	}
+}
 
-	// Check if cluster exists
-	cluster := &v1alpha1.Cluster{}
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: req.Namespace,
-		Name:      tikvGroup.Spec.Cluster.Name,
-	}, cluster); err != nil {
-		log.Error(err, "cluster not found")
-		return ctrl.Result{}, err
+// PreDelete marks the TiKV offline so that its store is removed from the cluster
+func (*Adapter) PreDelete(ctx context.Context, c client.Client, recorder record.EventRecorder,
+	tikvGroup *v1alpha1.TiKVGroup, tikv *v1alpha1.TiKV) error {
+	if tikv.Spec.Offline {
+		return nil
+	}
+	tikv.Spec.Offline = true
+	if err := c.Update(ctx, tikv); err != nil {
+		return err
 	}
+	recorder.Eventf(tikvGroup, corev1.EventTypeNormal, v1alpha1.ReasonOfflineProcessing, "store of %s is being offlined", tikv.Name)
+	return nil
+}
 
-	// List existing TiKV instances
-	var tikvList v1alpha1.TiKVList
-	if err := r.List(ctx, &tikvList, client.InNamespace(req.Namespace),
-		client.MatchingLabels{
-			v1alpha1.LabelKeyCluster:   tikvGroup.Spec.Cluster.Name,
-			v1alpha1.LabelKeyGroup:     tikvGroup.Name,
-			v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
-		}); err != nil {
-		return ctrl.Result{}, err
-	}
-
-	desiredReplicas := int32(0)
-	if tikvGroup.Spec.Replicas != nil {
-		desiredReplicas = *tikvGroup.Spec.Replicas
-	}
-	currentReplicas := int32(len(tikvList.Items))
-
-	// Scale out: create new TiKV instances
-	if desiredReplicas > currentReplicas {
-		for i := currentReplicas; i < desiredReplicas; i++ {
-			tikvName := fmt.Sprintf("%s-tikv-%d", tikvGroup.Name, i)
-			tikv := r.buildTiKV(tikvGroup, tikvName)
-			if err := controllerutil.SetControllerReference(tikvGroup, tikv, r.Scheme); err != nil {
-				return ctrl.Result{}, err
-			}
-			if err := r.Create(ctx, tikv); err != nil {
-				if !errors.IsAlreadyExists(err) {
-					log.Error(err, "failed to create TiKV instance", "name", tikvName)
-					return ctrl.Result{}, err
-				}
-			} else {
-				log.Info("created TiKV instance", "name", tikvName)
-			}
+// defaultMaxReplicas is the default replication factor of regions in PD
+const defaultMaxReplicas = 3
+
+// MaxUnavailable keeps the quorum of region replicas, the replication factor is max-replicas of PD.
+// Replicas of a region may be on stores of any TiKVGroup, so the budget is shared by all groups.
+// It's tightened by one while some stores are being offlined.
+func (*Adapter) MaxUnavailable(pdc pdapi.PDClient, _ []*v1alpha1.TiKVGroup, tikvs []*v1alpha1.TiKV) (int32, error) {
+	maxReplicas, err := getMaxReplicas(pdc)
+	if err != nil {
+		return 0, err
+	}
+	maxUnavailable := common.QuorumMaxUnavailable(maxReplicas)
+	for _, tikv := range tikvs {
+		if tikv.Spec.Offline {
+			maxUnavailable--
+			break
 		}
 	}
+	return maxUnavailable, nil
+}
+
+// MinReplicas keeps at least max-replicas Serving stores in the cluster,
+// including stores of other TiKVGroups, otherwise regions can't be fully replicated
+// and offline of stores never completes.
+func (*Adapter) MinReplicas(ctx context.Context, c client.Client, pdc pdapi.PDClient,
+	tikvGroup *v1alpha1.TiKVGroup) (int32, string, error) {
+	maxReplicas, err := getMaxReplicas(pdc)
+	if err != nil {
+		return 0, "", err
+	}
 
-	// Scale in: delete TiKV instances (in reverse order)
-	if desiredReplicas < currentReplicas {
-		toDelete := currentReplicas - desiredReplicas
-		for i := currentReplicas - 1; i >= desiredReplicas; i-- {
-			if toDelete <= 0 {
-				break
-			}
-			tikvName := fmt.Sprintf("%s-tikv-%d", tikvGroup.Name, i)
-			tikv := &v1alpha1.TiKV{}
-			if err := r.Get(ctx, types.NamespacedName{
-				Namespace: req.Namespace,
-				Name:      tikvName,
-			}, tikv); err != nil {
-				if !errors.IsNotFound(err) {
-					log.Error(err, "failed to get TiKV instance", "name", tikvName)
-					continue
-				}
-			} else {
-				// Mark as offline first for graceful deletion
-				if !tikv.Spec.Offline {
-					tikv.Spec.Offline = true
-					if err := r.Update(ctx, tikv); err != nil {
-						log.Error(err, "failed to mark TiKV offline", "name", tikvName)
-						continue
-					}
-				}
-				// TODO: Wait for offline completion before deletion
-				// For now, just delete directly
-				if err := r.Delete(ctx, tikv); err != nil {
-					log.Error(err, "failed to delete TiKV instance", "name", tikvName)
-					return ctrl.Result{}, err
-				}
-				log.Info("deleted TiKV instance", "name", tikvName)
-				toDelete--
-			}
+	var tikvList v1alpha1.TiKVList
+	if err := c.List(ctx, &tikvList, client.InNamespace(tikvGroup.Namespace),
+		client.MatchingLabels{v1alpha1.LabelKeyCluster: tikvGroup.Spec.Cluster.Name}); err != nil {
+		return 0, "", err
+	}
+	var others int32
+	for i := range tikvList.Items {
+		tikv := &tikvList.Items[i]
+		if tikv.Labels[v1alpha1.LabelKeyGroup] == tikvGroup.Name {
+			continue
+		}
+		if tikv.DeletionTimestamp.IsZero() && !tikv.Spec.Offline && tikv.Status.State == v1alpha1.StoreStateServing {
+			others++
 		}
 	}
 
-	// Update status
-	if err := r.updateStatus(ctx, tikvGroup, tikvList.Items); err != nil {
-		return ctrl.Result{}, err
+	minReplicas := maxReplicas - others
+	if minReplicas <= 0 {
+		return 0, "", nil
 	}
+	return minReplicas, fmt.Sprintf("max-replicas of PD is %d and other groups have %d Serving stores, "+
+		"at least %d stores of this group are kept", maxReplicas, others, minReplicas), nil
+}
 
-	return ctrl.Result{}, nil
+// getMaxReplicas returns the replication factor of regions
+func getMaxReplicas(pdc pdapi.PDClient) (int32, error) {
+	cfg, err := pdc.GetConfig()
+	if err != nil {
+		return 0, err
+	}
+	if cfg.Replication != nil && cfg.Replication.MaxReplicas != nil {
+		return int32(*cfg.Replication.MaxReplicas), nil
+	}
+	return defaultMaxReplicas, nil
 }
 
-func (r *TiKVGroupReconciler) buildTiKV(tikvGroup *v1alpha1.TiKVGroup, name string) *v1alpha1.TiKV {
-	tikv := &v1alpha1.TiKV{
-		ObjectMeta: ctrl.ObjectMeta{
-			Name:      name,
-			Namespace: tikvGroup.Namespace,
-			Labels: map[string]string{
-				v1alpha1.LabelKeyManagedBy: v1alpha1.LabelValManagedByOperator,
-				v1alpha1.LabelKeyCluster:   tikvGroup.Spec.Cluster.Name,
-				v1alpha1.LabelKeyComponent: v1alpha1.LabelValComponentTiKV,
-				v1alpha1.LabelKeyGroup:     tikvGroup.Name,
-				v1alpha1.LabelKeyInstance:  name,
-			},
-		},
-		Spec: v1alpha1.TiKVSpec{
-			Cluster:          tikvGroup.Spec.Cluster,
-			TiKVTemplateSpec: tikvGroup.Spec.Template.Spec,
-		},
+// ReplaceConcurrency returns the volume replace concurrency of the group, it's 1 by default
+func (*Adapter) ReplaceConcurrency(tikvGroup *v1alpha1.TiKVGroup) int32 {
+	if tikvGroup.Spec.VolumeReplaceConcurrency == nil {
+		return 1
 	}
-	return tikv
+	return *tikvGroup.Spec.VolumeReplaceConcurrency
 }
 
-func (r *TiKVGroupReconciler) updateStatus(ctx context.Context, tikvGroup *v1alpha1.TiKVGroup, tikvs []v1alpha1.TiKV) error {
-	readyReplicas := int32(0)
-	for _, tikv := range tikvs {
-		// Check if TiKV is ready (simplified check)
-		if tikv.Status.ID != "" && tikv.Status.State == v1alpha1.StoreStateServing {
-			readyReplicas++
-		}
+// IsDeletable returns whether the store of an offline TiKV is removed.
+// A TiKV whose store has never been registered can be deleted directly.
+func (*Adapter) IsDeletable(tikv *v1alpha1.TiKV) bool {
+	if !tikv.Spec.Offline {
+		return false
 	}
+	return tikv.Status.ID == "" || meta.IsStatusConditionTrue(tikv.Status.Conditions, v1alpha1.StoreOfflinedConditionType)
+}
 
-	replicas := int32(len(tikvs))
-	tikvGroup.Status.CommonStatus.ObservedGeneration = tikvGroup.Generation
-	tikvGroup.Status.GroupStatus.Replicas = replicas
-	tikvGroup.Status.GroupStatus.ReadyReplicas = readyReplicas
-	tikvGroup.Status.GroupStatus.CurrentReplicas = replicas
-	tikvGroup.Status.GroupStatus.UpdatedReplicas = replicas
-	tikvGroup.Status.GroupStatus.Selector = fmt.Sprintf("%s=%s,%s=%s",
-		v1alpha1.LabelKeyCluster, tikvGroup.Spec.Cluster.Name,
-		v1alpha1.LabelKeyGroup, tikvGroup.Name)
+// IsPreDeleted returns whether a TiKV is offline, it's deleted after its store is removed
+func (*Adapter) IsPreDeleted(tikv *v1alpha1.TiKV) bool {
+	return tikv.Spec.Offline
+}
 
-	return r.Status().Update(ctx, tikvGroup)
+// ExternalService returns the service which exposes the client port of TiKVs outside of the Kubernetes cluster
+func (*Adapter) ExternalService(tikvGroup *v1alpha1.TiKVGroup) *corev1.Service {
+	if tikvGroup.Spec.Service == nil {
+		return nil
+	}
+	return common.NewGroupExternalService(tikvGroup, tikvGroup.Spec.Service, []corev1.ServicePort{
+		{Name: v1alpha1.TiKVPortNameClient, Port: tikv.ClientPort(&tikvGroup.Spec.Template.Spec)},
+	})
 }
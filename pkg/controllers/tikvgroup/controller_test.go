@@ -0,0 +1,185 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tikvgroup
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/apis/core/v1alpha1"
+	"github.com/zhangjinpeng87/tikv-operator/pkg/pdapi"
+)
+
+func TestIsDeletable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	offlined := []metav1.Condition{{Type: v1alpha1.StoreOfflinedConditionType, Status: metav1.ConditionTrue}}
+	offlining := []metav1.Condition{{Type: v1alpha1.StoreOfflinedConditionType, Status: metav1.ConditionFalse}}
+	tcs := []struct {
+		caseName       string
+		offline        bool
+		id             string
+		conds          []metav1.Condition
+		wantPreDeleted bool
+		wantDeletable  bool
+	}{{
+		caseName: "serving",
+		id:       "1",
+	}, {
+		caseName: "not offline but offlined condition is left",
+		id:       "1",
+		conds:    offlined,
+	}, {
+		caseName:       "store is being offlined",
+		offline:        true,
+		id:             "1",
+		conds:          offlining,
+		wantPreDeleted: true,
+	}, {
+		caseName:       "store is removed",
+		offline:        true,
+		id:             "1",
+		conds:          offlined,
+		wantPreDeleted: true,
+		wantDeletable:  true,
+	}, {
+		caseName:       "store is not registered",
+		offline:        true,
+		wantPreDeleted: true,
+		wantDeletable:  true,
+	}}
+
+	for _, tc := range tcs {
+		tikv := &v1alpha1.TiKV{}
+		tikv.Spec.Offline = tc.offline
+		tikv.Status.ID = tc.id
+		tikv.Status.Conditions = tc.conds
+
+		a := &Adapter{}
+		g.Expect(a.IsPreDeleted(tikv)).To(Equal(tc.wantPreDeleted), tc.caseName)
+		g.Expect(a.IsDeletable(tikv)).To(Equal(tc.wantDeletable), tc.caseName)
+	}
+}
+
+func TestMaxUnavailable(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	five := uint64(5)
+	tcs := []struct {
+		caseName    string
+		maxReplicas *uint64
+		offline     []bool
+		want        int32
+	}{{
+		caseName: "default max replicas",
+		offline:  []bool{false, false, false},
+		want:     1,
+	}, {
+		caseName:    "5 replicas",
+		maxReplicas: &five,
+		offline:     []bool{false, false, false, false, false},
+		want:        2,
+	}, {
+		caseName: "store is being offlined",
+		offline:  []bool{false, true, false, false},
+		want:     0,
+	}, {
+		caseName:    "stores are being offlined",
+		maxReplicas: &five,
+		offline:     []bool{true, true, false, false, false},
+		want:        1,
+	}}
+
+	for _, tc := range tcs {
+		pdc := pdapi.NewFakePDClient()
+		pdc.AddReaction(pdapi.GetConfigActionType, func(*pdapi.Action) (interface{}, error) {
+			return &pdapi.PDConfigFromAPI{Replication: &pdapi.PDReplicationConfig{MaxReplicas: tc.maxReplicas}}, nil
+		})
+		var tikvs []*v1alpha1.TiKV
+		for _, offline := range tc.offline {
+			tikvs = append(tikvs, &v1alpha1.TiKV{Spec: v1alpha1.TiKVSpec{Offline: offline}})
+		}
+
+		mu, err := (&Adapter{}).MaxUnavailable(pdc, nil, tikvs)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(mu).To(Equal(tc.want), tc.caseName)
+	}
+}
+
+func TestMinReplicas(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	newTiKV := func(name, group, state string, offline bool) client.Object {
+		tikv := &v1alpha1.TiKV{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{
+			v1alpha1.LabelKeyCluster: "basic",
+			v1alpha1.LabelKeyGroup:   group,
+		}}}
+		tikv.Spec.Offline = offline
+		tikv.Status.State = state
+		return tikv
+	}
+
+	tcs := []struct {
+		caseName string
+		tikvs    []client.Object
+		want     int32
+	}{{
+		caseName: "single group",
+		tikvs: []client.Object{
+			newTiKV("tikv-tikv-0", "tikv", v1alpha1.StoreStateServing, false),
+			newTiKV("tikv-tikv-1", "tikv", v1alpha1.StoreStateServing, false),
+		},
+		want: 3,
+	}, {
+		caseName: "other groups have enough stores",
+		tikvs: []client.Object{
+			newTiKV("tikv-tikv-0", "tikv", v1alpha1.StoreStateServing, false),
+			newTiKV("other-tikv-0", "other", v1alpha1.StoreStateServing, false),
+			newTiKV("other-tikv-1", "other", v1alpha1.StoreStateServing, false),
+			newTiKV("other-tikv-2", "other", v1alpha1.StoreStateServing, false),
+		},
+	}, {
+		caseName: "stores of other groups which are not serving",
+		tikvs: []client.Object{
+			newTiKV("other-tikv-0", "other", v1alpha1.StoreStateServing, false),
+			newTiKV("other-tikv-1", "other", v1alpha1.StoreStatePreparing, false),
+			newTiKV("other-tikv-2", "other", v1alpha1.StoreStateServing, true),
+		},
+		want: 2,
+	}}
+
+	for _, tc := range tcs {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.tikvs...).Build()
+		pdc := pdapi.NewFakePDClient()
+		pdc.AddReaction(pdapi.GetConfigActionType, func(*pdapi.Action) (interface{}, error) {
+			return &pdapi.PDConfigFromAPI{}, nil
+		})
+		tikvGroup := &v1alpha1.TiKVGroup{ObjectMeta: metav1.ObjectMeta{Name: "tikv", Namespace: "ns"}}
+		tikvGroup.Spec.Cluster.Name = "basic"
+
+		minReplicas, msg, err := (&Adapter{}).MinReplicas(context.Background(), c, pdc, tikvGroup)
+		g.Expect(err).NotTo(HaveOccurred(), tc.caseName)
+		g.Expect(minReplicas).To(Equal(tc.want), tc.caseName)
+		g.Expect(msg == "").To(Equal(tc.want == 0), tc.caseName)
+	}
+}
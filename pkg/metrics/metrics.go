@@ -0,0 +1,161 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the operator specific Prometheus metrics.
+// They are registered to the controller-runtime registry and exported by the metrics server of the manager.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "tikv_operator"
+
+// Labels of metrics
+const (
+	LabelNamespace = "namespace"
+	LabelCluster   = "cluster"
+	LabelComponent = "component"
+	LabelGroup     = "group"
+	LabelInstance  = "instance"
+	LabelEndpoint  = "endpoint"
+	LabelMethod    = "method"
+	LabelState     = "state"
+	LabelType      = "type"
+)
+
+// Values of LabelType of GroupReplicas
+const (
+	ReplicasDesired = "desired"
+	ReplicasCurrent = "current"
+	ReplicasUpdated = "updated"
+	ReplicasReady   = "ready"
+)
+
+var (
+	// PDAPIRequestDuration is the latency of PD API requests
+	PDAPIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "pd_api",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of PD API requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{LabelNamespace, LabelCluster, LabelEndpoint, LabelMethod})
+
+	// PDAPIRequestErrors is the number of failed PD API requests,
+	// including requests which can't be sent and responses with a status code >= 400
+	PDAPIRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "pd_api",
+		Name:      "request_errors_total",
+		Help:      "Number of failed PD API requests.",
+	}, []string{LabelNamespace, LabelCluster, LabelEndpoint, LabelMethod})
+
+	// StoreState is 1 for the current store state of a TiKV instance
+	StoreState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "tikv",
+		Name:      "store_state",
+		Help:      "Store state of a TiKV instance, the value is 1 for the current state.",
+	}, []string{LabelNamespace, LabelCluster, LabelGroup, LabelInstance, LabelState})
+
+	// StoreOfflineStartTime is when the store of a TiKV instance began to be offlined.
+	// A timestamp instead of a duration is exported so that the offline duration,
+	// i.e. time() - value, keeps growing even if the instance is not reconciled.
+	StoreOfflineStartTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "tikv",
+		Name:      "store_offline_start_time_seconds",
+		Help:      "Unix time when the store of a TiKV instance began to be offlined.",
+	}, []string{LabelNamespace, LabelCluster, LabelGroup, LabelInstance})
+
+	// LeaderEvictionStartTime is when leaders of a TiKV instance began to be evicted
+	LeaderEvictionStartTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "tikv",
+		Name:      "leader_eviction_start_time_seconds",
+		Help:      "Unix time when leaders of a TiKV instance began to be evicted.",
+	}, []string{LabelNamespace, LabelCluster, LabelGroup, LabelInstance})
+
+	// GroupReplicas is the rollout progress of a group
+	GroupReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "group",
+		Name:      "replicas",
+		Help:      "Number of desired, current, updated and ready instances of a group.",
+	}, []string{LabelNamespace, LabelCluster, LabelComponent, LabelGroup, LabelType})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		PDAPIRequestDuration,
+		PDAPIRequestErrors,
+		StoreState,
+		StoreOfflineStartTime,
+		LeaderEvictionStartTime,
+		GroupReplicas,
+	)
+}
+
+// ObservePDAPIRequest records the latency and the error of a PD API request
+func ObservePDAPIRequest(ns, cluster, endpoint, method string, start time.Time, err error) {
+	labels := prometheus.Labels{
+		LabelNamespace: ns,
+		LabelCluster:   cluster,
+		LabelEndpoint:  endpoint,
+		LabelMethod:    method,
+	}
+	PDAPIRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	if err != nil {
+		PDAPIRequestErrors.With(labels).Inc()
+	}
+}
+
+// SetStoreState sets the current store state of a TiKV instance.
+// An empty state means the store is unknown.
+func SetStoreState(ns, cluster, group, instance, state string) {
+	StoreState.DeletePartialMatch(prometheus.Labels{LabelNamespace: ns, LabelInstance: instance})
+	if state == "" {
+		return
+	}
+	StoreState.WithLabelValues(ns, cluster, group, instance, state).Set(1)
+}
+
+// SetGroupReplicas sets the rollout progress of a group
+func SetGroupReplicas(ns, cluster, component, group string, desired, current, updated, ready int32) {
+	for typ, v := range map[string]int32{
+		ReplicasDesired: desired,
+		ReplicasCurrent: current,
+		ReplicasUpdated: updated,
+		ReplicasReady:   ready,
+	} {
+		GroupReplicas.WithLabelValues(ns, cluster, component, group, typ).Set(float64(v))
+	}
+}
+
+// DeleteInstance deletes all metrics of an instance
+func DeleteInstance(ns, instance string) {
+	labels := prometheus.Labels{LabelNamespace: ns, LabelInstance: instance}
+	StoreState.DeletePartialMatch(labels)
+	StoreOfflineStartTime.DeletePartialMatch(labels)
+	LeaderEvictionStartTime.DeletePartialMatch(labels)
+}
+
+// DeleteGroup deletes all metrics of a group
+func DeleteGroup(ns, component, group string) {
+	labels := prometheus.Labels{LabelNamespace: ns, LabelComponent: component, LabelGroup: group}
+	GroupReplicas.DeletePartialMatch(labels)
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhangjinpeng87/tikv-operator/pkg/metrics"
+)
+
+// instrumentedTransport records metrics of all requests sent by a pdClient,
+// both reads by getBodyOK and writes
+type instrumentedTransport struct {
+	namespace Namespace
+	cluster   string
+	next      http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+
+	reqErr := err
+	if err == nil && res.StatusCode >= http.StatusBadRequest {
+		reqErr = fmt.Errorf("status code %d", res.StatusCode)
+	}
+	metrics.ObservePDAPIRequest(string(t.namespace), t.cluster, endpointOf(req.URL.Path), req.Method, start, reqErr)
+
+	return res, err
+}
+
+// endpointOf returns the endpoint of an api path with ids and names replaced by placeholders,
+// e.g. "pd/api/v1/store/1/state" => "store/:id/state", to keep the cardinality of metrics low
+func endpointOf(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, "pd/api/v1/")
+	path = strings.TrimPrefix(path, "pd/")
+
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if _, err := strconv.ParseUint(seg, 10, 64); err == nil {
+			segments[i] = ":id"
+			continue
+		}
+		if i > 0 {
+			switch segments[i-1] {
+			case "name", "transfer", "schedulers":
+				segments[i] = ":name"
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You can obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdapi
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestEndpointOf(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		path string
+		want string
+	}{
+		{path: fmt.Sprintf("/%s", healthPrefix), want: "health"},
+		{path: fmt.Sprintf("/%s", storesPrefix), want: "stores"},
+		{path: fmt.Sprintf("/%s/1", storePrefix), want: "store/:id"},
+		{path: fmt.Sprintf("/%s/12/state", storePrefix), want: "store/:id/state"},
+		{path: fmt.Sprintf("/%s/name/pd-0", membersPrefix), want: "members/name/:name"},
+		{path: fmt.Sprintf("/%s/id/3", membersPrefix), want: "members/id/:id"},
+		{path: fmt.Sprintf("/%s/%s", schedulersPrefix, getLeaderEvictSchedulerStr(1)), want: "schedulers/:name"},
+		{path: fmt.Sprintf("/%s/pd-1", pdLeaderTransferPrefix), want: "leader/transfer/:name"},
+	}
+
+	for _, tc := range tcs {
+		g.Expect(endpointOf(tc.path)).To(Equal(tc.want), tc.path)
+	}
+}
@@ -21,7 +21,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,12 +45,39 @@ const (
 // Namespace is a newtype of a string
 type Namespace string
 
+// DefaultClientPort is the default client port of PD
+const DefaultClientPort = 2379
+
+// Option configures how a client connects to PD
+type Option func(*clientConfig)
+
+type clientConfig struct {
+	clientPort int32
+}
+
+// ClientPort specifies the client port of the PD service, DefaultClientPort is used if it's not specified
+func ClientPort(port int32) Option {
+	return func(c *clientConfig) {
+		if port > 0 {
+			c.clientPort = port
+		}
+	}
+}
+
+func newClientConfig(opts []Option) *clientConfig {
+	c := &clientConfig{clientPort: DefaultClientPort}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
 // PDControlInterface is an interface that knows how to manage and get tidb cluster's PD client
 type PDControlInterface interface {
 	// GetPDClient provides PDClient of the tidb cluster.
-	GetPDClient(Namespace, string, bool) PDClient
+	GetPDClient(namespace Namespace, tcName string, tlsEnabled bool, opts ...Option) PDClient
 	// GetPDEtcdClient provides PD etcd Client of the tidb cluster.
-	GetPDEtcdClient(namespace Namespace, tcName string, tlsEnabled bool) (PDEtcdClient, error)
+	GetPDEtcdClient(namespace Namespace, tcName string, tlsEnabled bool, opts ...Option) (PDEtcdClient, error)
 }
 
 // defaultPDControl is the default implementation of PDControlInterface.
@@ -121,10 +150,12 @@ func loadTLSConfigFromSecret(secret *corev1.Secret, caCert []byte) (*tls.Config,
 	}, nil
 }
 
-func (pdc *defaultPDControl) GetPDEtcdClient(namespace Namespace, tcName string, tlsEnabled bool) (PDEtcdClient, error) {
+func (pdc *defaultPDControl) GetPDEtcdClient(namespace Namespace, tcName string, tlsEnabled bool, opts ...Option) (PDEtcdClient, error) {
 	pdc.etcdmutex.Lock()
 	defer pdc.etcdmutex.Unlock()
 
+	cfg := newClientConfig(opts)
+
 	var tlsConfig *tls.Config
 	var err error
 
@@ -134,11 +165,11 @@ func (pdc *defaultPDControl) GetPDEtcdClient(namespace Namespace, tcName string,
 			klog.Errorf("Unable to get tls config for tidb cluster %q, pd etcd client may not work: %v", tcName, err)
 			return nil, err
 		}
-		return NewPdEtcdClient(PDEtcdClientURL(namespace, tcName), DefaultTimeout, tlsConfig)
+		return NewPdEtcdClient(PDEtcdClientURL(namespace, tcName, cfg.clientPort), DefaultTimeout, tlsConfig)
 	}
-	key := pdEtcdClientKey(namespace, tcName)
+	key := pdEtcdClientKey(namespace, tcName, cfg.clientPort)
 	if _, ok := pdc.pdEtcdClients[key]; !ok {
-		pdetcdClient, err := NewPdEtcdClient(PDEtcdClientURL(namespace, tcName), DefaultTimeout, nil)
+		pdetcdClient, err := NewPdEtcdClient(PDEtcdClientURL(namespace, tcName, cfg.clientPort), DefaultTimeout, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -148,10 +179,12 @@ func (pdc *defaultPDControl) GetPDEtcdClient(namespace Namespace, tcName string,
 }
 
 // GetPDClient provides a PDClient of real pd cluster,if the PDClient not existing, it will create new one.
-func (pdc *defaultPDControl) GetPDClient(namespace Namespace, tcName string, tlsEnabled bool) PDClient {
+func (pdc *defaultPDControl) GetPDClient(namespace Namespace, tcName string, tlsEnabled bool, opts ...Option) PDClient {
 	pdc.mutex.Lock()
 	defer pdc.mutex.Unlock()
 
+	cfg := newClientConfig(opts)
+
 	var tlsConfig *tls.Config
 	var err error
 	var scheme = "http"
@@ -161,35 +194,40 @@ func (pdc *defaultPDControl) GetPDClient(namespace Namespace, tcName string, tls
 		tlsConfig, err = GetTLSConfig(pdc.kubeCli, namespace, tcName, nil)
 		if err != nil {
 			klog.Errorf("Unable to get tls config for tidb cluster %q, pd client may not work: %v", tcName, err)
-			return &pdClient{url: PdClientURL(namespace, tcName, scheme), httpClient: &http.Client{Timeout: DefaultTimeout}}
+			return newPDClient(PdClientURL(namespace, tcName, scheme, cfg.clientPort), DefaultTimeout, nil, namespace, tcName)
 		}
 
-		return NewPDClient(PdClientURL(namespace, tcName, scheme), DefaultTimeout, tlsConfig)
+		return newPDClient(PdClientURL(namespace, tcName, scheme, cfg.clientPort), DefaultTimeout, tlsConfig, namespace, tcName)
 	}
 
-	key := pdClientKey(scheme, namespace, tcName)
+	key := pdClientKey(scheme, namespace, tcName, cfg.clientPort)
 	if _, ok := pdc.pdClients[key]; !ok {
-		pdc.pdClients[key] = NewPDClient(PdClientURL(namespace, tcName, scheme), DefaultTimeout, nil)
+		pdc.pdClients[key] = newPDClient(PdClientURL(namespace, tcName, scheme, cfg.clientPort), DefaultTimeout, nil, namespace, tcName)
 	}
 	return pdc.pdClients[key]
 }
 
 // pdClientKey returns the pd client key
-func pdClientKey(scheme string, namespace Namespace, clusterName string) string {
-	return fmt.Sprintf("%s.%s.%s", scheme, clusterName, string(namespace))
+func pdClientKey(scheme string, namespace Namespace, clusterName string, port int32) string {
+	return fmt.Sprintf("%s.%s.%s.%d", scheme, clusterName, string(namespace), port)
+}
+
+func pdEtcdClientKey(namespace Namespace, clusterName string, port int32) string {
+	return fmt.Sprintf("%s.%s.%d", clusterName, string(namespace), port)
 }
 
-func pdEtcdClientKey(namespace Namespace, clusterName string) string {
-	return fmt.Sprintf("%s.%s", clusterName, string(namespace))
+// JoinHostPort combines a host and a port, an IPv6 host is enclosed in brackets
+func JoinHostPort(host string, port int32) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
 }
 
 // pdClientUrl builds the url of pd client
-func PdClientURL(namespace Namespace, clusterName string, scheme string) string {
-	return fmt.Sprintf("%s://%s-pd.%s:2379", scheme, clusterName, string(namespace))
+func PdClientURL(namespace Namespace, clusterName string, scheme string, port int32) string {
+	return fmt.Sprintf("%s://%s", scheme, PDEtcdClientURL(namespace, clusterName, port))
 }
 
-func PDEtcdClientURL(namespace Namespace, clusterName string) string {
-	return fmt.Sprintf("%s-pd.%s:2379", clusterName, string(namespace))
+func PDEtcdClientURL(namespace Namespace, clusterName string, port int32) string {
+	return JoinHostPort(fmt.Sprintf("%s-pd.%s", clusterName, string(namespace)), port)
 }
 
 // PDClient provides pd server's api
@@ -215,6 +253,8 @@ type PDClient interface {
 	UpdateReplicationConfig(config PDReplicationConfig) error
 	// DeleteStore deletes a TiKV store from cluster
 	DeleteStore(storeID uint64) error
+	// RemoveTombStoneStores removes all tombstone stores from cluster
+	RemoveTombStoneStores() error
 	// SetStoreState sets store to specified state.
 	SetStoreState(storeID uint64, state string) error
 	// DeleteMember deletes a PD member from cluster
@@ -255,11 +295,20 @@ type pdClient struct {
 
 // NewPDClient returns a new PDClient
 func NewPDClient(url string, timeout time.Duration, tlsConfig *tls.Config) PDClient {
+	return newPDClient(url, timeout, tlsConfig, "", "")
+}
+
+// newPDClient returns a new PDClient of a cluster, metrics of requests are labeled by the cluster
+func newPDClient(url string, timeout time.Duration, tlsConfig *tls.Config, namespace Namespace, clusterName string) PDClient {
 	return &pdClient{
 		url: url,
 		httpClient: &http.Client{
-			Timeout:   timeout,
-			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			Timeout: timeout,
+			Transport: &instrumentedTransport{
+				namespace: namespace,
+				cluster:   clusterName,
+				next:      &http.Transport{TLSClientConfig: tlsConfig},
+			},
 		},
 	}
 }
@@ -466,6 +515,28 @@ func (pc *pdClient) DeleteStore(storeID uint64) error {
 	return fmt.Errorf("failed to delete store %d: %v", storeID, string(body))
 }
 
+func (pc *pdClient) RemoveTombStoneStores() error {
+	apiURL := fmt.Sprintf("%s/%s/remove-tombstone", pc.url, storesPrefix)
+	req, err := http.NewRequest("DELETE", apiURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := pc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httputil.DeferClose(res.Body)
+
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("failed to remove tombstone stores: %v", string(body))
+}
+
 // SetStoreState sets store to specified state.
 func (pc *pdClient) SetStoreState(storeID uint64, state string) error {
 	apiURL := fmt.Sprintf("%s/%s/%d/state?state=%s", pc.url, storePrefix, storeID, state)
@@ -746,6 +817,19 @@ func getLeaderEvictSchedulerStr(storeID uint64) string {
 	return fmt.Sprintf("%s-%d", "evict-leader-scheduler", storeID)
 }
 
+// ParseEvictLeaderSchedulerStoreID returns the store id of an evict-leader scheduler name
+func ParseEvictLeaderSchedulerStoreID(name string) (uint64, bool) {
+	idStr, ok := strings.CutPrefix(name, "evict-leader-scheduler-")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
 type FakePDControl struct {
 	defaultPDControl
 }
@@ -757,7 +841,7 @@ func NewFakePDControl(kubeCli kubernetes.Interface) *FakePDControl {
 }
 
 func (fpc *FakePDControl) SetPDClient(namespace Namespace, tcName string, pdclient PDClient) {
-	fpc.defaultPDControl.pdClients[pdClientKey("http", namespace, tcName)] = pdclient
+	fpc.defaultPDControl.pdClients[pdClientKey("http", namespace, tcName, DefaultClientPort)] = pdclient
 }
 
 type ActionType string
@@ -771,6 +855,7 @@ const (
 	GetTombStoneStoresActionType       ActionType = "GetTombStoneStores"
 	GetStoreActionType                 ActionType = "GetStore"
 	DeleteStoreActionType              ActionType = "DeleteStore"
+	RemoveTombStoneStoresActionType    ActionType = "RemoveTombStoneStores"
 	SetStoreStateActionType            ActionType = "SetStoreState"
 	DeleteMemberByIDActionType         ActionType = "DeleteMemberByID"
 	DeleteMemberActionType             ActionType = "DeleteMember "
@@ -898,6 +983,14 @@ func (pc *FakePDClient) DeleteStore(id uint64) error {
 	return nil
 }
 
+func (pc *FakePDClient) RemoveTombStoneStores() error {
+	if reaction, ok := pc.reactions[RemoveTombStoneStoresActionType]; ok {
+		_, err := reaction(&Action{})
+		return err
+	}
+	return nil
+}
+
 func (pc *FakePDClient) SetStoreState(id uint64, state string) error {
 	if reaction, ok := pc.reactions[SetStoreStateActionType]; ok {
 		action := &Action{ID: id}
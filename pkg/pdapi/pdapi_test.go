@@ -648,3 +648,33 @@ func readJSON(r io.ReadCloser, data interface{}) error {
 
 	return nil
 }
+
+func TestParseEvictLeaderSchedulerStoreID(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	tcs := []struct {
+		name   string
+		wantID uint64
+		wantOK bool
+	}{
+		{name: getLeaderEvictSchedulerStr(12), wantID: 12, wantOK: true},
+		{name: "evict-leader-scheduler-x"},
+		{name: "balance-leader-scheduler"},
+	}
+
+	for _, tc := range tcs {
+		id, ok := ParseEvictLeaderSchedulerStoreID(tc.name)
+		g.Expect(ok).To(Equal(tc.wantOK), tc.name)
+		g.Expect(id).To(Equal(tc.wantID), tc.name)
+	}
+}
+
+func TestClientURL(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(PdClientURL("ns", "basic", "http", 2379)).To(Equal("http://basic-pd.ns:2379"))
+	g.Expect(PDEtcdClientURL("ns", "basic", 12379)).To(Equal("basic-pd.ns:12379"))
+	g.Expect(JoinHostPort("10.0.0.1", 2379)).To(Equal("10.0.0.1:2379"))
+	g.Expect(JoinHostPort("fd00::1", 2379)).To(Equal("[fd00::1]:2379"))
+	g.Expect(JoinHostPort("::", 2379)).To(Equal("[::]:2379"))
+}